@@ -0,0 +1,398 @@
+package alerts
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/stakestar/startracker/db"
+	"github.com/stakestar/startracker/versions"
+	"go.uber.org/zap"
+)
+
+// event types, rules are defined for one of them
+const (
+	EventNodeOffline     = "node_offline"
+	EventNodeOnline      = "node_online"
+	EventCountryChanged  = "country_changed"
+	EventProviderChanged = "provider_changed"
+	EventOutdatedVersion = "outdated_version"
+)
+
+const (
+	eventQueueSize        = 1024
+	defaultMaxRetries     = 5
+	defaultRetryBackoff   = 2 * time.Second
+	defaultCheckInterval  = 5 * time.Minute
+	defaultStaleThreshold = time.Hour
+)
+
+type Config struct {
+	// StaleAfter is how long a node can go unseen before it is reported offline
+	StaleAfter    time.Duration  `yaml:"StaleAfter" env:"ALERTS_STALE_AFTER" env-default:"1h" env-description:"Time without handshake after which a node is offline"`
+	CheckInterval time.Duration  `yaml:"CheckInterval" env:"ALERTS_CHECK_INTERVAL" env-default:"5m" env-description:"Interval between offline node checks"`
+	MaxRetries    int            `yaml:"MaxRetries" env:"ALERTS_MAX_RETRIES" env-default:"5" env-description:"Webhook delivery attempts before an alert is dropped"`
+	RetryBackoff  time.Duration  `yaml:"RetryBackoff" env:"ALERTS_RETRY_BACKOFF" env-default:"2s" env-description:"Initial delay between webhook delivery attempts, doubled on every retry"`
+	Rules         []Rule         `yaml:"Rules"`
+	Subscriptions []Subscription `yaml:"Subscriptions"`
+}
+
+// Rule enables an event type, MinVersion is required by outdated_version rules
+type Rule struct {
+	Name       string `yaml:"Name"`
+	Event      string `yaml:"Event"`
+	MinVersion string `yaml:"MinVersion"`
+}
+
+// Subscription delivers the alerts of some rules and operators to a webhook,
+// empty OperatorIDs or Rules match everything
+type Subscription struct {
+	Name        string   `yaml:"Name"`
+	URL         string   `yaml:"URL"`
+	Secret      string   `yaml:"Secret"`
+	Format      string   `yaml:"Format"`
+	OperatorIDs []uint64 `yaml:"OperatorIDs"`
+	Rules       []string `yaml:"Rules"`
+}
+
+func (s *Subscription) matches(event *Event) bool {
+	return matchesAny(s.Rules, event.Rule) && matchesAny(s.OperatorIDs, event.OperatorID)
+}
+
+func matchesAny[T comparable](values []T, value T) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Event is an alert raised by a rule
+type Event struct {
+	Type       string     `json:"type"`
+	Rule       string     `json:"rule"`
+	OperatorID uint64     `json:"operator_id"`
+	Message    string     `json:"message"`
+	Node       EventNode  `json:"node"`
+	Previous   *EventNode `json:"previous,omitempty"`
+	Time       time.Time  `json:"time"`
+
+	// nodeID names the node in messages when its operator id is not known yet
+	nodeID string
+}
+
+// EventNode is the node of an event, webhooks are third party endpoints so the node ip is left out
+type EventNode struct {
+	UpdatedAt          time.Time  `json:"updated_at"`
+	AddressFamily      string     `json:"address_family"`
+	GeoData            db.GeoData `json:"geo_data"`
+	NodeVersion        string     `json:"node_version"`
+	OperatorIDContract uint64     `json:"operator_id"`
+}
+
+func newEventNode(node *db.NodeData) EventNode {
+	return EventNode{
+		UpdatedAt:          node.UpdatedAt,
+		AddressFamily:      node.AddressFamily,
+		GeoData:            node.GeoData,
+		NodeVersion:        node.NodeVersion,
+		OperatorIDContract: node.OperatorIDContract,
+	}
+}
+
+// Alerter evaluates the rules on node data changes and on a staleness check,
+// and delivers the resulting events to the subscribed webhooks
+type Alerter struct {
+	config *Config
+	db     db.Store
+	logger *zap.Logger
+	sender *webhookSender
+
+	events chan Event
+	// stopped is closed by Stop, the workers then deliver the queued events and return
+	stopped        chan struct{}
+	workers        sync.WaitGroup
+	cancelDelivery context.CancelFunc
+
+	mu sync.Mutex
+	// offline maps the nodes considered offline to whether an alert was raised for them
+	offline map[string]bool
+	primed  bool
+}
+
+func New(config *Config, db db.Store, logger *zap.Logger) (*Alerter, error) {
+	for _, rule := range config.Rules {
+		switch rule.Event {
+		case EventNodeOffline, EventCountryChanged, EventProviderChanged:
+		case EventOutdatedVersion:
+			if _, err := versions.Parse(rule.MinVersion); err != nil {
+				return nil, fmt.Errorf("invalid MinVersion of alert rule %s: %w", rule.Name, err)
+			}
+		default:
+			return nil, fmt.Errorf("unknown event %s of alert rule %s", rule.Event, rule.Name)
+		}
+	}
+	for _, subscription := range config.Subscriptions {
+		if err := validateFormat(subscription.Format); err != nil {
+			return nil, fmt.Errorf("invalid subscription %s: %w", subscription.Name, err)
+		}
+	}
+	if config.MaxRetries <= 0 {
+		config.MaxRetries = defaultMaxRetries
+	}
+	if config.RetryBackoff <= 0 {
+		config.RetryBackoff = defaultRetryBackoff
+	}
+	if config.CheckInterval <= 0 {
+		config.CheckInterval = defaultCheckInterval
+	}
+	if config.StaleAfter <= 0 {
+		config.StaleAfter = defaultStaleThreshold
+	}
+
+	logger = logger.With(zap.String("who", "Alerter"))
+	return &Alerter{
+		config:  config,
+		db:      db,
+		logger:  logger,
+		sender:  newWebhookSender(config, logger),
+		events:  make(chan Event, eventQueueSize),
+		stopped: make(chan struct{}),
+		offline: make(map[string]bool),
+	}, nil
+}
+
+// Enabled reports whether any rule and subscription is configured
+func (a *Alerter) Enabled() bool {
+	return len(a.config.Rules) > 0 && len(a.config.Subscriptions) > 0
+}
+
+// Start runs the staleness checker until the context is done and the delivery workers until Stop,
+// so that the alerts raised before the shutdown are still delivered
+func (a *Alerter) Start(ctx context.Context) {
+	deliveryCtx, cancel := context.WithCancel(context.Background())
+	a.cancelDelivery = cancel
+	queues := make([]chan Event, len(a.config.Subscriptions))
+	for i := range a.config.Subscriptions {
+		queues[i] = make(chan Event, eventQueueSize)
+		a.workers.Add(1)
+		go func(subscription *Subscription, queue <-chan Event) {
+			defer a.workers.Done()
+			a.deliver(deliveryCtx, subscription, queue)
+		}(&a.config.Subscriptions[i], queues[i])
+	}
+	go a.dispatch(queues)
+	if a.rule(EventNodeOffline) != nil {
+		go a.checkStaleness(ctx)
+	}
+}
+
+// Stop delivers the queued alerts until the context is done, the pending deliveries are then cancelled.
+// Alerts raised after Stop are not delivered
+func (a *Alerter) Stop(ctx context.Context) error {
+	close(a.stopped)
+	done := make(chan struct{})
+	go func() {
+		a.workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		a.cancelDelivery()
+		return ctx.Err()
+	}
+}
+
+// OnNodeData is registered as a db.NodeDataListener to detect changes in stored nodes
+func (a *Alerter) OnNodeData(previous, current *db.NodeData) {
+	// geo data refreshes keep the last seen time and do not mean the node is back
+	seen := previous == nil || current.UpdatedAt.After(previous.UpdatedAt)
+	if rule := a.rule(EventNodeOffline); rule != nil && seen {
+		a.mu.Lock()
+		alerted := a.offline[current.OperatorID]
+		delete(a.offline, current.OperatorID)
+		a.mu.Unlock()
+		if alerted {
+			a.raise(rule, EventNodeOnline, current, previous, "node is back online")
+		}
+	}
+
+	if previous == nil {
+		if rule := a.rule(EventOutdatedVersion); rule != nil && isOutdated(current.NodeVersion, rule.MinVersion) {
+			a.raise(rule, EventOutdatedVersion, current, previous,
+				fmt.Sprintf("node runs outdated version %s, minimum is %s", current.NodeVersion, rule.MinVersion))
+		}
+		return
+	}
+
+	if rule := a.rule(EventCountryChanged); rule != nil {
+		before, after := previous.GeoData.CountryCode, current.GeoData.CountryCode
+		if before != "" && after != "" && before != after {
+			a.raise(rule, EventCountryChanged, current, previous,
+				fmt.Sprintf("node moved from %s to %s", countryName(&previous.GeoData), countryName(&current.GeoData)))
+		}
+	}
+
+	if rule := a.rule(EventProviderChanged); rule != nil {
+		before, after := previous.GeoData, current.GeoData
+		if before.ASN != 0 && after.ASN != 0 && before.ASN != after.ASN {
+			a.raise(rule, EventProviderChanged, current, previous,
+				fmt.Sprintf("node moved from AS%d %s to AS%d %s", before.ASN, before.Organization, after.ASN, after.Organization))
+		}
+	}
+
+	if rule := a.rule(EventOutdatedVersion); rule != nil && previous.NodeVersion != current.NodeVersion && isOutdated(current.NodeVersion, rule.MinVersion) {
+		a.raise(rule, EventOutdatedVersion, current, previous,
+			fmt.Sprintf("node runs outdated version %s, minimum is %s", current.NodeVersion, rule.MinVersion))
+	}
+}
+
+// checkStaleness reports nodes that were not seen for StaleAfter,
+// nodes already stale on the first check are tracked without alerting to avoid a burst on restart
+func (a *Alerter) checkStaleness(ctx context.Context) {
+	ticker := time.NewTicker(a.config.CheckInterval)
+	defer ticker.Stop()
+	for {
+		a.checkOfflineNodes()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (a *Alerter) checkOfflineNodes() {
+	rule := a.rule(EventNodeOffline)
+	nodes, err := a.db.ListNodeData(false)
+	if err != nil {
+		a.logger.Error("could not list nodes", zap.Error(err))
+		return
+	}
+
+	var stale []db.NodeData
+	a.mu.Lock()
+	primed := a.primed
+	a.primed = true
+	for _, node := range nodes {
+		if _, ok := a.offline[node.OperatorID]; ok || time.Since(node.UpdatedAt) < a.config.StaleAfter {
+			continue
+		}
+		a.offline[node.OperatorID] = primed
+		if primed {
+			stale = append(stale, node)
+		}
+	}
+	a.mu.Unlock()
+
+	for i := range stale {
+		node := &stale[i]
+		a.raise(rule, EventNodeOffline, node, nil,
+			fmt.Sprintf("node was not seen since %s", node.UpdatedAt.UTC().Format(time.RFC3339)))
+	}
+}
+
+func (a *Alerter) rule(event string) *Rule {
+	for i := range a.config.Rules {
+		if a.config.Rules[i].Event == event {
+			return &a.config.Rules[i]
+		}
+	}
+	return nil
+}
+
+func (a *Alerter) raise(rule *Rule, eventType string, node, previous *db.NodeData, message string) {
+	event := Event{
+		Type:       eventType,
+		Rule:       rule.Name,
+		OperatorID: node.OperatorIDContract,
+		Message:    message,
+		Node:       newEventNode(node),
+		Time:       time.Now(),
+		nodeID:     node.OperatorID,
+	}
+	if previous != nil {
+		eventNode := newEventNode(previous)
+		event.Previous = &eventNode
+	}
+	select {
+	case a.events <- event:
+	default:
+		a.logger.Warn("alert queue is full, dropping alert", zap.String("type", eventType), zap.Uint64("operatorId", event.OperatorID))
+	}
+}
+
+// dispatch queues every event for the matching subscriptions, every subscription has its own worker
+// so that a slow or failing webhook retrying its deliveries does not hold up the others.
+// Once stopped, the raised events are queued and the queues closed
+func (a *Alerter) dispatch(queues []chan Event) {
+	defer func() {
+		for _, queue := range queues {
+			close(queue)
+		}
+	}()
+	for {
+		select {
+		case event := <-a.events:
+			a.route(queues, &event)
+		case <-a.stopped:
+			for {
+				select {
+				case event := <-a.events:
+					a.route(queues, &event)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (a *Alerter) route(queues []chan Event, event *Event) {
+	for i := range a.config.Subscriptions {
+		subscription := &a.config.Subscriptions[i]
+		if !subscription.matches(event) {
+			continue
+		}
+		select {
+		case queues[i] <- *event:
+		default:
+			a.logger.Warn("webhook queue is full, dropping alert", zap.String("subscription", subscription.Name), zap.String("type", event.Type))
+		}
+	}
+}
+
+// deliver sends the queued events of the subscription in order until the queue is closed or the context is done
+func (a *Alerter) deliver(ctx context.Context, subscription *Subscription, queue <-chan Event) {
+	for event := range queue {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := a.sender.send(ctx, subscription, &event); err != nil {
+			a.logger.Error("could not deliver alert", zap.String("subscription", subscription.Name), zap.String("type", event.Type), zap.Error(err))
+		}
+	}
+}
+
+func countryName(geoData *db.GeoData) string {
+	if geoData.CountryName != "" {
+		return geoData.CountryName
+	}
+	return geoData.CountryCode
+}
+
+// isOutdated reports whether version is lower than minVersion, unparsable versions are not reported
+func isOutdated(version, minVersion string) bool {
+	min, err := versions.Parse(minVersion)
+	if err != nil {
+		return false
+	}
+	return versions.IsOutdated(version, min)
+}
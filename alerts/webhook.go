@@ -0,0 +1,133 @@
+package alerts
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// webhook payload formats
+const (
+	FormatGeneric = "generic"
+	FormatSlack   = "slack"
+	FormatDiscord = "discord"
+)
+
+const (
+	// signatureHeader carries the hex encoded HMAC-SHA256 of the timestamp, a dot and the body,
+	// keyed with the subscription secret, receivers can reject old timestamps to prevent replays
+	signatureHeader = "X-Startracker-Signature"
+	// timestampHeader carries the unix time of the delivery attempt
+	timestampHeader = "X-Startracker-Timestamp"
+	eventHeader     = "X-Startracker-Event"
+)
+
+func validateFormat(format string) error {
+	switch format {
+	case "", FormatGeneric, FormatSlack, FormatDiscord:
+		return nil
+	default:
+		return fmt.Errorf("unknown webhook format: %s", format)
+	}
+}
+
+type webhookSender struct {
+	config *Config
+	client *http.Client
+	logger *zap.Logger
+}
+
+func newWebhookSender(config *Config, logger *zap.Logger) *webhookSender {
+	return &webhookSender{
+		config: config,
+		client: &http.Client{Timeout: 10 * time.Second},
+		logger: logger,
+	}
+}
+
+// send delivers the event to the subscription, retrying with exponential backoff
+// on network errors, rate limits and server errors
+func (s *webhookSender) send(ctx context.Context, subscription *Subscription, event *Event) error {
+	body, err := payload(subscription.Format, event)
+	if err != nil {
+		return err
+	}
+
+	backoff := s.config.RetryBackoff
+	for attempt := 1; ; attempt++ {
+		retry, err := s.post(ctx, subscription, event, body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= s.config.MaxRetries {
+			return err
+		}
+		s.logger.Debug("webhook delivery failed, retrying", zap.String("subscription", subscription.Name), zap.Int("attempt", attempt), zap.Error(err))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (s *webhookSender) post(ctx context.Context, subscription *Subscription, event *Event, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(eventHeader, event.Type)
+	if subscription.Secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(timestampHeader, timestamp)
+		req.Header.Set(signatureHeader, "sha256="+sign(subscription.Secret, timestamp, body))
+	}
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return false, nil
+	}
+	retry := res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500
+	return retry, fmt.Errorf("unexpected webhook response status: %s", res.Status)
+}
+
+func sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func payload(format string, event *Event) ([]byte, error) {
+	switch format {
+	case FormatSlack:
+		return json.Marshal(map[string]string{"text": text(event)})
+	case FormatDiscord:
+		return json.Marshal(map[string]string{"content": text(event)})
+	default:
+		return json.Marshal(event)
+	}
+}
+
+func text(event *Event) string {
+	if event.OperatorID == 0 {
+		return fmt.Sprintf("[%s] node %s: %s", event.Rule, event.nodeID, event.Message)
+	}
+	return fmt.Sprintf("[%s] operator %d: %s", event.Rule, event.OperatorID, event.Message)
+}
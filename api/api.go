@@ -1,29 +1,67 @@
 package api
 
 import (
+	"context"
+	"crypto/subtle"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/bloxapp/ssv/utils/format"
-	"github.com/gin-contrib/cache"
-	"github.com/gin-contrib/cache/persistence"
 	"github.com/gin-gonic/gin"
+	"github.com/graphql-go/graphql"
 	"github.com/stakestar/startracker/db"
+	"github.com/stakestar/startracker/keys"
+	"github.com/stakestar/startracker/utils"
+	"github.com/stakestar/startracker/versions"
 	"github.com/ulule/limiter/v3"
 	ginlimiter "github.com/ulule/limiter/v3/drivers/middleware/gin"
 	"github.com/ulule/limiter/v3/drivers/store/memory"
 	"go.uber.org/zap"
 )
 
+const (
+	// maxClaimAge is how old the timestamp of a submitted location claim can be
+	maxClaimAge = 24 * time.Hour
+	// maxClaimClockSkew is how far in the future the timestamp of a submitted location claim can be
+	maxClaimClockSkew = 5 * time.Minute
+)
+
+type Config struct {
+	ListenAddress string        `yaml:"ListenAddress" env:"API_LISTEN_ADDRESS" env-default:":8080" env-description:"Address the API server listens on"`
+	AdminToken    string        `yaml:"AdminToken" env:"API_ADMIN_TOKEN" env-description:"Bearer token for the admin endpoints, admin endpoints are disabled if empty"`
+	OnlineWithin  time.Duration `yaml:"OnlineWithin" env:"API_ONLINE_WITHIN" env-default:"1h" env-description:"Operators whose node was seen within this duration are online"`
+	ReadOnly      bool          `yaml:"ReadOnly" env:"API_READ_ONLY" env-default:"false" env-description:"Disable endpoints that write to the database"`
+	Network       string        `yaml:"Network" env:"API_NETWORK" env-description:"Network reported in the metadata of v1 responses, the p2p network id if empty"`
+	Compress      bool          `yaml:"Compress" env:"API_COMPRESS" env-default:"true" env-description:"Compress responses with brotli or gzip when the client accepts it"`
+	Privacy       PrivacyConfig `yaml:"Privacy"`
+	GraphQL       GraphQLConfig `yaml:"GraphQL"`
+}
+
 type Api struct {
-	db     *db.BoltDB
-	logger *zap.Logger
+	db       db.Store
+	logger   *zap.Logger
+	config   *Config
+	versions *versions.Tracker
+	server   *http.Server
+	cache    responseCache
+	clusters clusterCache
+	latest   latestVersionCache
+	// instance distinguishes the revision etags of different runs, the configuration may have changed
+	instance string
+	limiter  *limiter.Limiter
+	schema   graphql.Schema
 }
 
-func New(logger *zap.Logger, db *db.BoltDB) *Api {
+func New(logger *zap.Logger, db db.Store, config *Config, versions *versions.Tracker) *Api {
 	return &Api{
-		db:     db,
-		logger: logger,
+		db:       db,
+		logger:   logger,
+		config:   config,
+		versions: versions,
+		server:   &http.Server{Addr: config.ListenAddress},
+		instance: strconv.FormatInt(time.Now().UnixNano(), 36),
 	}
 }
 
@@ -37,98 +75,401 @@ func (api *Api) Start() {
 		Period: time.Minute,
 	}
 	store := memory.NewStore()
-	limit := limiter.New(store, rate)
+	api.limiter = limiter.New(store, rate)
 
 	// use the rate limiter middleware
-	router.Use(ginlimiter.NewMiddleware(limit))
-
-	// add cache middleware
-	cacheStore := persistence.NewInMemoryStore(time.Minute)
+	router.Use(ginlimiter.NewMiddleware(api.limiter, ginlimiter.WithLimitReachedHandler(func(c *gin.Context) {
+		abort(c, http.StatusTooManyRequests, codeRateLimited, "rate limit exceeded")
+	})))
+	if api.config.Compress {
+		router.Use(api.compress)
+	}
 
-	router.GET("/api/nodes", cache.CachePage(cacheStore, time.Minute, api.GetNodes))
-	router.GET("/api/nodes/all", cache.CachePage(cacheStore, time.Minute, api.GetAllNodes))
-	router.GET("/api/nodes/pubkey/:pubkey", cache.CachePage(cacheStore, time.Minute, api.GetNodeByPubKey))
-	router.GET("/api/nodes/operatorid/:operatorid", cache.CachePage(cacheStore, time.Minute, api.GetNodeByOperatorId))
+	api.routes(router.Group(v1Prefix))
+	api.routes(router.Group("/api", deprecated))
 
-	api.logger.Info("Starting server")
-	err := router.Run(":8080")
+	schema, err := newGraphQLSchema()
 	if err != nil {
+		api.logger.Fatal("Error building GraphQL schema", zap.Error(err))
+	}
+	api.schema = schema
+	router.GET(graphqlPath, api.GraphQL)
+	router.POST(graphqlPath, api.GraphQL)
+	router.NoRoute(abortNotFound)
+
+	api.server.Handler = router
+
+	api.logger.Info("Starting server", zap.String("address", api.config.ListenAddress))
+	err = api.server.ListenAndServe()
+	if err != nil && err != http.ErrServerClosed {
 		api.logger.Fatal("Error starting server", zap.Error(err))
 	}
 }
 
+// routes registers the endpoints under the group, once for v1 and once for the deprecated unversioned paths
+func (api *Api) routes(group *gin.RouterGroup) {
+	nodeList := api.cached
+	if nodePrivacyOnly(&api.config.Privacy) {
+		// the listings are streamed from the store, buffering them in the cache would defeat it
+		nodeList = api.conditional
+	}
+	group.GET("/nodes", nodeList(api.GetNodes))
+	group.GET("/nodes/all", nodeList(api.GetAllNodes))
+	group.GET("/nodes/pubkey/:pubkey", api.cached(api.GetNodeByPubKey))
+	group.GET("/nodes/operatorid/:operatorid", api.cached(api.GetNodeByOperatorId))
+	group.GET("/nodes/ip/:ip", api.cached(api.GetNodesByIP))
+	group.GET("/nodes/near", api.cached(api.GetNodesNear))
+	group.GET("/nodes/bbox", api.cached(api.GetNodesInBox))
+	group.GET("/nodes/clusters", api.cached(api.GetClusters))
+	group.GET("/tiles/:z/:x/:y", api.cached(api.GetClusterTile))
+	if !api.config.ReadOnly {
+		group.POST("/nodes/operatorid/:operatorid/claim", api.SubmitLocationClaim)
+	}
+	group.GET("/versions", api.cached(api.GetVersions))
+	group.GET("/operators", api.cached(api.GetOperators))
+	group.GET("/operators/:operatorid", api.cached(api.GetOperator))
+	group.GET("/entities", api.cached(api.GetEntities))
+	group.GET("/stats", api.cached(api.GetStats))
+	group.GET("/openapi.json", api.GetOpenAPI)
+
+	if api.config.AdminToken != "" {
+		admin := group.Group("/admin", api.adminAuth)
+		admin.GET("/nodes", api.AdminGetAllNodes)
+		admin.GET("/nodes/pubkey/:pubkey", api.AdminGetNodeByPubKey)
+		admin.GET("/nodes/operatorid/:operatorid", api.AdminGetNodeByOperatorId)
+		admin.GET("/nodes/ip/:ip", api.AdminGetNodesByIP)
+		admin.GET("/snapshot", api.AdminGetSnapshot)
+	}
+}
+
+// Shutdown stops accepting connections and waits for the active requests
+func (api *Api) Shutdown(ctx context.Context) error {
+	return api.server.Shutdown(ctx)
+}
+
+// allNodes lists all nodes with their outdated flag set
+func (api *Api) allNodes() ([]db.NodeData, error) {
+	nodes, err := api.db.ListNodeData(false)
+	if err != nil {
+		return nil, err
+	}
+	latest := api.versions.Latest(nodes)
+	for i := range nodes {
+		nodes[i].Outdated = versions.IsOutdated(nodes[i].NodeVersion, latest)
+		if err := api.verifyClaim(&nodes[i]); err != nil {
+			return nil, err
+		}
+	}
+	return nodes, nil
+}
+
+// decorateNodes sets the outdated flag of nodes read from the store, public nodes also get the privacy rules
+// of a single node, so public nodes can only be decorated on their own when nodePrivacyOnly holds
+func (api *Api) decorateNodes(nodes []db.NodeData, public bool) ([]db.NodeData, error) {
+	latest, err := api.latestVersion()
+	if err != nil {
+		return nil, err
+	}
+	for i := range nodes {
+		nodes[i].Outdated = versions.IsOutdated(nodes[i].NodeVersion, latest)
+		if public {
+			applyNodePrivacy(&api.config.Privacy, &nodes[i])
+		}
+		if err := api.verifyClaim(&nodes[i]); err != nil {
+			return nil, err
+		}
+	}
+	return nodes, nil
+}
+
+// publicNodes lists all nodes with the privacy rules applied
+func (api *Api) publicNodes() ([]db.NodeData, error) {
+	nodes, err := api.allNodes()
+	if err != nil {
+		return nil, err
+	}
+	return applyPrivacy(&api.config.Privacy, nodes), nil
+}
+
+// publicNode returns the given node with the privacy rules applied, all nodes are only
+// loaded when the privacy rules depend on the full node set
+func (api *Api) publicNode(nodeData *db.NodeData) (*db.NodeData, error) {
+	if nodePrivacyOnly(&api.config.Privacy) {
+		nodes, err := api.decorateNodes([]db.NodeData{*nodeData}, true)
+		if err != nil {
+			return nil, err
+		}
+		return &nodes[0], nil
+	}
+	nodes, err := api.publicNodes()
+	if err != nil {
+		return nil, err
+	}
+	for i := range nodes {
+		if nodes[i].OperatorID == nodeData.OperatorID {
+			return &nodes[i], nil
+		}
+	}
+	return nil, db.ErrNotFound
+}
+
 func (api *Api) GetNodes(c *gin.Context) {
-	nodes, err := api.db.ListNodeData(true)
+	if c.Query("cidr") != "" {
+		if !api.ipLookupAllowed(c) {
+			return
+		}
+		matched, ok := api.nodesInCIDR(c)
+		if ok {
+			api.respondPublicIPNodes(c, matched, true)
+		}
+		return
+	}
+	if nodePrivacyOnly(&api.config.Privacy) {
+		api.streamNodes(c, true, true)
+		return
+	}
+	nodes, err := api.publicNodes()
 	if err != nil {
 		api.logger.Error("Error getting nodes", zap.Error(err))
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		abortInternal(c)
 		return
 	}
-	// Add metadata to response
-	response := gin.H{
-		"nodes": nodes,
-		"metadata": gin.H{
-			"count": len(nodes),
-		},
+	withOperatorId := make([]db.NodeData, 0, len(nodes))
+	for _, node := range nodes {
+		if node.OperatorIDContract != 0 {
+			withOperatorId = append(withOperatorId, node)
+		}
 	}
-	c.JSON(http.StatusOK, response)
+	api.respondNodes(c, withOperatorId)
 }
 
 func (api *Api) GetAllNodes(c *gin.Context) {
-	nodes, err := api.db.ListNodeData(false)
+	if nodePrivacyOnly(&api.config.Privacy) {
+		api.streamNodes(c, false, true)
+		return
+	}
+	nodes, err := api.publicNodes()
 	if err != nil {
 		api.logger.Error("Error getting nodes", zap.Error(err))
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		abortInternal(c)
 		return
 	}
-	// Add metadata to response
-	response := gin.H{
-		"nodes": nodes,
-		"metadata": gin.H{
-			"count": len(nodes),
-		},
-	}
-	c.JSON(http.StatusOK, response)
+	api.respondNodes(c, nodes)
 }
 
 func (api *Api) GetNodeByPubKey(c *gin.Context) {
-	pubkey := c.Param("pubkey")
-	if pubkey == "" {
-		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "pubkey is required"})
+	nodeData, ok := api.nodeByPubKey(c)
+	if !ok {
 		return
 	}
-	nodeData, err := api.db.GetNodeData(format.OperatorID([]byte(pubkey)))
-	if err != nil {
-		status := http.StatusInternalServerError
-		msg := "internal server error"
-		if err == db.ErrNotFound {
-			status = http.StatusNotFound
-			msg = "not found"
+	api.respondPublicNode(c, nodeData)
+}
+
+func (api *Api) GetNodeByOperatorId(c *gin.Context) {
+	nodeData, ok := api.nodeByOperatorId(c)
+	if !ok {
+		return
+	}
+	api.respondPublicNode(c, nodeData)
+}
+
+func (api *Api) AdminGetAllNodes(c *gin.Context) {
+	if c.Query("cidr") != "" {
+		matched, ok := api.nodesInCIDR(c)
+		if ok {
+			api.respondAdminIPNodes(c, matched)
 		}
+		return
+	}
+	api.streamNodes(c, false, false)
+}
+
+func (api *Api) AdminGetNodeByPubKey(c *gin.Context) {
+	nodeData, ok := api.nodeByPubKey(c)
+	if !ok {
+		return
+	}
+	api.respondAdminNode(c, nodeData)
+}
+
+func (api *Api) AdminGetNodeByOperatorId(c *gin.Context) {
+	nodeData, ok := api.nodeByOperatorId(c)
+	if !ok {
+		return
+	}
+	api.respondAdminNode(c, nodeData)
+}
+
+func (api *Api) respondNodes(c *gin.Context, nodes []db.NodeData) {
+	api.respondList(c, "nodes", nodes, gin.H{
+		"count": len(nodes),
+	})
+}
+
+func (api *Api) respondPublicNode(c *gin.Context, nodeData *db.NodeData) {
+	nodeData, err := api.publicNode(nodeData)
+	if err != nil {
+		api.logger.Error("Error applying privacy rules", zap.Error(err))
+		abortInternal(c)
+		return
+	}
+	api.respondNode(c, nodeData)
+}
+
+func (api *Api) respondAdminNode(c *gin.Context, nodeData *db.NodeData) {
+	nodes, err := api.decorateNodes([]db.NodeData{*nodeData}, false)
+	if err != nil {
 		api.logger.Error("Error getting node", zap.Error(err))
-		c.AbortWithStatusJSON(status, gin.H{"error": msg})
+		abortInternal(c)
 		return
 	}
-	c.JSON(http.StatusOK, nodeData)
+	api.respondNode(c, &nodes[0])
 }
 
-func (api *Api) GetNodeByOperatorId(c *gin.Context) {
+// respondNode responds with the node joined with its operator
+func (api *Api) respondNode(c *gin.Context, nodeData *db.NodeData) {
+	response, err := api.withOperator(nodeData)
+	if err != nil {
+		api.logger.Error("Error getting operator", zap.Error(err))
+		abortInternal(c)
+		return
+	}
+	api.respond(c, response)
+}
+
+func (api *Api) nodeByPubKey(c *gin.Context) (*db.NodeData, bool) {
+	pubkey := c.Param("pubkey")
+	if pubkey == "" {
+		abort(c, http.StatusBadRequest, codeInvalidParameter, "pubkey is required")
+		return nil, false
+	}
+	nodeData, err := api.db.GetNodeData(format.OperatorID([]byte(pubkey)))
+	if err != nil {
+		api.abortWithNodeError(c, err)
+		return nil, false
+	}
+	return nodeData, true
+}
+
+func (api *Api) nodeByOperatorId(c *gin.Context) (*db.NodeData, bool) {
 	operatodId := c.Param("operatorid")
 	if operatodId == "" {
-		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "operatorid is required"})
-		return
+		abort(c, http.StatusBadRequest, codeInvalidParameter, "operatorid is required")
+		return nil, false
 	}
 	nodeData, err := api.db.GetNodeByOperatorContractId(operatodId)
 	if err != nil {
-		status := http.StatusInternalServerError
-		msg := "internal server error"
+		api.abortWithNodeError(c, err)
+		return nil, false
+	}
+	return nodeData, true
+}
+
+func (api *Api) abortWithNodeError(c *gin.Context, err error) {
+	api.logger.Error("Error getting node", zap.Error(err))
+	if err == db.ErrNotFound {
+		abortNotFound(c)
+		return
+	}
+	abortInternal(c)
+}
+
+// adminAuth only lets requests with the admin bearer token through
+func (api *Api) adminAuth(c *gin.Context) {
+	token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(api.config.AdminToken)) != 1 {
+		abort(c, http.StatusUnauthorized, codeUnauthorized, "unauthorized")
+		return
+	}
+	c.Next()
+}
+
+func (api *Api) SubmitLocationClaim(c *gin.Context) {
+	operatorIdContract, err := utils.StringToUint64(c.Param("operatorid"))
+	if err != nil {
+		abort(c, http.StatusBadRequest, codeInvalidParameter, "invalid operatorid")
+		return
+	}
+
+	var claim db.LocationClaim
+	if err := c.ShouldBindJSON(&claim); err != nil {
+		abort(c, http.StatusBadRequest, codeInvalidParameter, "invalid claim")
+		return
+	}
+	claim.OperatorIDContract = operatorIdContract
+	claim.CountryCode = strings.ToUpper(claim.CountryCode)
+
+	if len(claim.CountryCode) != 2 || claim.Latitude < -90 || claim.Latitude > 90 || claim.Longitude < -180 || claim.Longitude > 180 {
+		abort(c, http.StatusBadRequest, codeInvalidParameter, "invalid location")
+		return
+	}
+	claimedAt := time.Unix(claim.Timestamp, 0)
+	if time.Since(claimedAt) > maxClaimAge || time.Until(claimedAt) > maxClaimClockSkew {
+		abort(c, http.StatusBadRequest, codeInvalidParameter, "claim timestamp is too old or in the future")
+		return
+	}
+
+	operator, err := api.db.GetOperatorByOperatorIdContract(operatorIdContract)
+	if err != nil {
+		api.logger.Error("Error getting operator", zap.Error(err))
 		if err == db.ErrNotFound {
-			status = http.StatusNotFound
-			msg = "not found"
+			abortNotFound(c)
+			return
 		}
-		api.logger.Error("Error getting node", zap.Error(err))
-		c.AbortWithStatusJSON(status, gin.H{"error": msg})
+		abortInternal(c)
 		return
 	}
-	c.JSON(http.StatusOK, nodeData)
+
+	previous, err := api.db.GetLocationClaim(operator.OperatorID)
+	if err != nil && err != db.ErrNotFound {
+		api.logger.Error("Error getting location claim", zap.Error(err))
+		abortInternal(c)
+		return
+	}
+	if previous != nil && previous.Timestamp >= claim.Timestamp {
+		abort(c, http.StatusConflict, codeClaimOutdated, "a newer claim already exists")
+		return
+	}
+
+	publicKey, err := keys.ParsePublicKey(operator.PublicKey)
+	if err != nil {
+		api.logger.Error("Error parsing operator public key", zap.Uint64("operatorId", operatorIdContract), zap.Error(err))
+		abortInternal(c)
+		return
+	}
+	if err := keys.Verify(publicKey, claim.Message(), claim.Signature); err != nil {
+		abort(c, http.StatusUnauthorized, codeInvalidSignature, "invalid signature")
+		return
+	}
+
+	if err := api.db.SaveLocationClaim(operator.OperatorID, &claim); err != nil {
+		api.logger.Error("Error saving location claim", zap.Error(err))
+		abortInternal(c)
+		return
+	}
+	claim.Verified = true
+	api.respond(c, claim)
+}
+
+// verifyClaim sets the verified flag of the node claim. The signature is checked against the current operator key
+// when the claim is served, so that claims signed with a rotated key are not shown as verified
+func (api *Api) verifyClaim(node *db.NodeData) error {
+	if node.ClaimedGeo == nil {
+		return nil
+	}
+	node.ClaimedGeo.Verified = false
+	operator, err := api.db.GetOperatorByOperatorId(node.OperatorID)
+	if err == db.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	publicKey, err := keys.ParsePublicKey(operator.PublicKey)
+	if err != nil {
+		api.logger.Warn("Error parsing operator public key", zap.String("operatorId", node.OperatorID), zap.Error(err))
+		return nil
+	}
+	node.ClaimedGeo.Verified = keys.Verify(publicKey, node.ClaimedGeo.Message(), node.ClaimedGeo.Signature) == nil
+	return nil
 }
@@ -0,0 +1,231 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	// responseCacheMaxAge bounds how long a response is reused while the revision does not change,
+	// some fields like the operator status depend on the time and not only on the data
+	responseCacheMaxAge = time.Minute
+	// maxCachedResponses empties the cache when reached, it bounds the memory used by distinct query strings
+	maxCachedResponses = 10000
+)
+
+type cachedResponse struct {
+	contentType string
+	body        []byte
+	etag        string
+	created     time.Time
+
+	mu      sync.Mutex
+	encoded map[string][]byte
+}
+
+// encode returns the body compressed with the encoding, every encoding is compressed once per response
+func (r *cachedResponse) encode(encoding string) ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if body, ok := r.encoded[encoding]; ok {
+		return body, nil
+	}
+	body, err := encodeBody(encoding, r.body)
+	if err != nil {
+		return nil, err
+	}
+	if r.encoded == nil {
+		r.encoded = make(map[string][]byte)
+	}
+	r.encoded[encoding] = body
+	return body, nil
+}
+
+// responseCache keeps the responses of the current data revision, it is emptied when the revision changes
+type responseCache struct {
+	mu       sync.Mutex
+	revision uint64
+	entries  map[string]*cachedResponse
+}
+
+func (rc *responseCache) get(revision uint64, key string) (*cachedResponse, bool) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if rc.revision != revision {
+		return nil, false
+	}
+	response, ok := rc.entries[key]
+	if !ok || time.Since(response.created) > responseCacheMaxAge {
+		return nil, false
+	}
+	return response, true
+}
+
+func (rc *responseCache) put(revision uint64, key string, response *cachedResponse) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if revision < rc.revision {
+		return
+	}
+	if rc.revision != revision || rc.entries == nil || len(rc.entries) >= maxCachedResponses {
+		rc.revision = revision
+		rc.entries = make(map[string]*cachedResponse)
+	}
+	rc.entries[key] = response
+}
+
+// cacheKey identifies a response by method, path and query, query parameters are sorted
+func cacheKey(r *http.Request) string {
+	return r.Method + " " + r.URL.Path + "?" + r.URL.Query().Encode()
+}
+
+// bufferedWriter holds the response of a handler so that it can be cached before being sent
+type bufferedWriter struct {
+	gin.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *bufferedWriter) WriteHeader(code int) {
+	if code > 0 {
+		w.status = code
+	}
+}
+
+func (w *bufferedWriter) WriteHeaderNow() {}
+
+func (w *bufferedWriter) Write(data []byte) (int, error) {
+	return w.body.Write(data)
+}
+
+func (w *bufferedWriter) WriteString(s string) (int, error) {
+	return w.body.WriteString(s)
+}
+
+func (w *bufferedWriter) Status() int {
+	return w.status
+}
+
+func (w *bufferedWriter) Size() int {
+	return w.body.Len()
+}
+
+func (w *bufferedWriter) Written() bool {
+	return w.body.Len() > 0
+}
+
+// cached serves the successful responses of the handler from the cache of the current data revision,
+// with an ETag of the revision and the body, and answers 304 to a matching If-None-Match.
+// Large responses are compressed once per encoding and kept with the cached body
+func (api *Api) cached(handler gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		revision, err := api.db.Revision()
+		if err != nil {
+			api.logger.Error("Error getting data revision", zap.Error(err))
+			abortInternal(c)
+			return
+		}
+
+		key := cacheKey(c.Request)
+		response, ok := api.cache.get(revision.Number, key)
+		if !ok {
+			writer := &bufferedWriter{ResponseWriter: c.Writer, status: http.StatusOK}
+			c.Writer = writer
+			handler(c)
+			c.Writer = writer.ResponseWriter
+			if writer.status != http.StatusOK {
+				c.Data(writer.status, writer.Header().Get("Content-Type"), writer.body.Bytes())
+				return
+			}
+
+			body := writer.body.Bytes()
+			sum := sha256.Sum256(body)
+			response = &cachedResponse{
+				contentType: writer.Header().Get("Content-Type"),
+				body:        body,
+				etag:        `"` + strconv.FormatUint(revision.Number, 10) + "-" + hex.EncodeToString(sum[:8]) + `"`,
+				created:     time.Now(),
+			}
+			api.cache.put(revision.Number, key, response)
+		}
+
+		encoding := api.encoding(c)
+		if len(response.body) < minCompressSize {
+			encoding = ""
+		}
+		etag := response.etag
+		if encoding != "" {
+			etag = weakETag(etag)
+		}
+		c.Header("ETag", etag)
+		c.Header("Cache-Control", "no-cache")
+		if !revision.UpdatedAt.IsZero() {
+			c.Header("Last-Modified", revision.UpdatedAt.UTC().Format(http.TimeFormat))
+		}
+		if etagMatches(c.GetHeader("If-None-Match"), response.etag) {
+			c.AbortWithStatus(http.StatusNotModified)
+			return
+		}
+
+		body := response.body
+		if encoding != "" {
+			encoded, err := response.encode(encoding)
+			if err != nil {
+				api.logger.Error("Error compressing response", zap.String("encoding", encoding), zap.Error(err))
+				abortInternal(c)
+				return
+			}
+			c.Header("Content-Encoding", encoding)
+			body = encoded
+		}
+		c.Data(http.StatusOK, response.contentType, body)
+	}
+}
+
+// conditional answers 304 to a matching If-None-Match for the handlers streaming their body, their responses are
+// not cached and the weak ETag is made of the data revision only, so the body does not need to be buffered
+func (api *Api) conditional(handler gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		revision, err := api.db.Revision()
+		if err != nil {
+			api.logger.Error("Error getting data revision", zap.Error(err))
+			abortInternal(c)
+			return
+		}
+
+		etag := `W/"` + api.instance + "-" + strconv.FormatUint(revision.Number, 10) + `"`
+		c.Header("ETag", etag)
+		c.Header("Cache-Control", "no-cache")
+		if !revision.UpdatedAt.IsZero() {
+			c.Header("Last-Modified", revision.UpdatedAt.UTC().Format(http.TimeFormat))
+		}
+		if etagMatches(c.GetHeader("If-None-Match"), etag) {
+			c.AbortWithStatus(http.StatusNotModified)
+			return
+		}
+		handler(c)
+	}
+}
+
+// etagMatches reports whether an If-None-Match header lists the etag, weak comparison as required for GET
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
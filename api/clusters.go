@@ -0,0 +1,160 @@
+package api
+
+import (
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stakestar/startracker/db"
+	"go.uber.org/zap"
+)
+
+const maxClusterZoom = 22
+
+// cluster aggregates the located nodes of a geohash cell
+type cluster struct {
+	Geohash   string  `json:"geohash"`
+	Count     int     `json:"count"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// clusterPrecision returns the geohash length giving about eight cells across a map tile of the zoom level
+func clusterPrecision(zoom int) int {
+	precision := int(math.Round(float64(zoom+3) * 2 / 5))
+	if precision < 1 {
+		return 1
+	}
+	if precision > 9 {
+		return 9
+	}
+	return precision
+}
+
+// buildClusters groups the located nodes by geohash, the cluster position is the centroid of its nodes
+func buildClusters(nodes []db.NodeData, precision int) []cluster {
+	centroids := make(map[string]*centroid)
+	for i := range nodes {
+		geoData := &nodes[i].GeoData
+		if geoData.Latitude == 0 && geoData.Longitude == 0 {
+			continue
+		}
+		hash := db.Geohash(geoData.Latitude, geoData.Longitude, precision)
+		if centroids[hash] == nil {
+			centroids[hash] = &centroid{}
+		}
+		centroids[hash].add(geoData)
+	}
+	clusters := make([]cluster, 0, len(centroids))
+	for hash, c := range centroids {
+		var position db.GeoData
+		c.apply(&position)
+		clusters = append(clusters, cluster{
+			Geohash:   hash,
+			Count:     c.count,
+			Latitude:  position.Latitude,
+			Longitude: position.Longitude,
+		})
+	}
+	sort.Slice(clusters, func(i, j int) bool { return clusters[i].Geohash < clusters[j].Geohash })
+	return clusters
+}
+
+// clusterCache keeps the clusters of the whole map per zoom level for a data revision,
+// zoom levels with the same precision share an entry
+type clusterCache struct {
+	mu       sync.Mutex
+	revision uint64
+	entries  map[int][]cluster
+}
+
+func (api *Api) clustersAt(zoom int) ([]cluster, error) {
+	precision := clusterPrecision(zoom)
+	revision, err := api.db.Revision()
+	if err != nil {
+		return nil, err
+	}
+
+	api.clusters.mu.Lock()
+	defer api.clusters.mu.Unlock()
+	if api.clusters.entries == nil || api.clusters.revision != revision.Number {
+		api.clusters.revision = revision.Number
+		api.clusters.entries = make(map[int][]cluster)
+	}
+	if clusters, ok := api.clusters.entries[precision]; ok {
+		return clusters, nil
+	}
+	nodes, err := api.publicNodes()
+	if err != nil {
+		return nil, err
+	}
+	clusters := buildClusters(nodes, precision)
+	api.clusters.entries[precision] = clusters
+	return clusters, nil
+}
+
+func parseZoom(value string) (int, bool) {
+	zoom, err := strconv.Atoi(value)
+	if err != nil || zoom < 0 || zoom > maxClusterZoom {
+		return 0, false
+	}
+	return zoom, true
+}
+
+func (api *Api) GetClusters(c *gin.Context) {
+	zoom, ok := parseZoom(c.Query("zoom"))
+	if !ok {
+		abort(c, http.StatusBadRequest, codeInvalidParameter, "invalid zoom")
+		return
+	}
+
+	// bounds are optional, the whole map is returned without them
+	var boxes []db.GeoBox
+	if c.Query("min_lat") != "" || c.Query("min_lon") != "" || c.Query("max_lat") != "" || c.Query("max_lon") != "" {
+		var bounds [4]float64
+		for i, param := range []struct {
+			name  string
+			limit float64
+		}{{"min_lat", 90}, {"min_lon", 180}, {"max_lat", 90}, {"max_lon", 180}} {
+			value, ok := parseCoordinate(c, param.name, param.limit)
+			if !ok {
+				return
+			}
+			bounds[i] = value
+		}
+		boxes = geoBoxes(bounds[0], bounds[1], bounds[2], bounds[3])
+	}
+
+	clusters, err := api.clustersAt(zoom)
+	if err != nil {
+		api.logger.Error("Error building clusters", zap.Error(err))
+		abortInternal(c)
+		return
+	}
+	if boxes != nil {
+		inBounds := make([]cluster, 0)
+		for _, cl := range clusters {
+			for _, box := range boxes {
+				if box.Contains(cl.Latitude, cl.Longitude) {
+					inBounds = append(inBounds, cl)
+					break
+				}
+			}
+		}
+		clusters = inBounds
+	}
+
+	nodes := 0
+	for _, cl := range clusters {
+		nodes += cl.Count
+	}
+	api.respondList(c, "clusters", clusters, gin.H{
+		"count":     len(clusters),
+		"nodes":     nodes,
+		"zoom":      zoom,
+		"precision": clusterPrecision(zoom),
+	})
+}
@@ -0,0 +1,251 @@
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+)
+
+// content encodings, brotli is preferred when the client accepts both with the same quality
+const (
+	encodingBrotli = "br"
+	encodingGzip   = "gzip"
+)
+
+// minCompressSize is the size under which responses are sent uncompressed, the encoding overhead outweighs the gain
+const minCompressSize = 1024
+
+// encoder is implemented by the gzip and brotli writers
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+var encoderPools = map[string]*sync.Pool{
+	encodingBrotli: {New: func() interface{} {
+		return brotli.NewWriterLevel(nil, brotli.DefaultCompression)
+	}},
+	encodingGzip: {New: func() interface{} {
+		return gzip.NewWriter(nil)
+	}},
+}
+
+// negotiateEncoding returns the supported encoding with the highest quality in an Accept-Encoding header,
+// or an empty string for the identity encoding
+func negotiateEncoding(acceptEncoding string) string {
+	if acceptEncoding == "" {
+		return ""
+	}
+	qualities := make(map[string]float64)
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		quality := 1.0
+		for _, param := range strings.Split(params, ";") {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				q, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64)
+				if err != nil {
+					q = 0
+				}
+				quality = q
+			}
+		}
+		qualities[strings.ToLower(strings.TrimSpace(name))] = quality
+	}
+
+	quality := func(encoding string) float64 {
+		if q, ok := qualities[encoding]; ok {
+			return q
+		}
+		return qualities["*"]
+	}
+	brotliQuality, gzipQuality := quality(encodingBrotli), quality(encodingGzip)
+	switch {
+	case brotliQuality > 0 && brotliQuality >= gzipQuality:
+		return encodingBrotli
+	case gzipQuality > 0:
+		return encodingGzip
+	default:
+		return ""
+	}
+}
+
+// encodeBody returns the body compressed with the encoding
+func encodeBody(encoding string, body []byte) ([]byte, error) {
+	pool := encoderPools[encoding]
+	enc := pool.Get().(encoder)
+	defer func() {
+		enc.Reset(io.Discard)
+		pool.Put(enc)
+	}()
+
+	var buffer bytes.Buffer
+	enc.Reset(&buffer)
+	if _, err := enc.Write(body); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// weakETag marks an etag as weak, the etags are computed on the uncompressed body
+func weakETag(etag string) string {
+	if strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	return "W/" + etag
+}
+
+// encoding returns the encoding negotiated with the client, empty if compression is disabled
+func (api *Api) encoding(c *gin.Context) string {
+	if !api.config.Compress || c.Request.Method == http.MethodHead {
+		return ""
+	}
+	return negotiateEncoding(c.GetHeader("Accept-Encoding"))
+}
+
+// compress encodes the responses with the encoding negotiated with the client. The first minCompressSize bytes
+// are held back to send small responses uncompressed, responses that already have an encoding are sent as is
+func (api *Api) compress(c *gin.Context) {
+	c.Writer.Header().Add("Vary", "Accept-Encoding")
+	encoding := api.encoding(c)
+	if encoding == "" {
+		c.Next()
+		return
+	}
+
+	writer := &compressWriter{
+		ResponseWriter: c.Writer,
+		encoding:       encoding,
+		status:         c.Writer.Status(),
+	}
+	c.Writer = writer
+	defer func() {
+		c.Writer = writer.ResponseWriter
+		if err := writer.finish(); err != nil {
+			_ = c.Error(err)
+		}
+	}()
+	c.Next()
+}
+
+// compressWriter compresses the body once minCompressSize bytes are written or the handler flushes
+type compressWriter struct {
+	gin.ResponseWriter
+	encoding string
+	status   int
+	pending  []byte
+	started  bool
+	encoder  encoder
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	if code > 0 && !w.started {
+		w.status = code
+	}
+}
+
+func (w *compressWriter) WriteHeaderNow() {}
+
+func (w *compressWriter) Write(data []byte) (int, error) {
+	if !w.started {
+		w.pending = append(w.pending, data...)
+		if len(w.pending) < minCompressSize {
+			return len(data), nil
+		}
+		if err := w.start(true); err != nil {
+			return 0, err
+		}
+		return len(data), nil
+	}
+	if w.encoder != nil {
+		return w.encoder.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *compressWriter) Status() int {
+	return w.status
+}
+
+func (w *compressWriter) Written() bool {
+	return w.started || len(w.pending) > 0
+}
+
+func (w *compressWriter) Flush() {
+	if !w.started {
+		if err := w.start(true); err != nil {
+			return
+		}
+	}
+	if w.encoder != nil {
+		if err := w.encoder.Flush(); err != nil {
+			return
+		}
+	}
+	w.ResponseWriter.Flush()
+}
+
+// start sends the header and the pending bytes, compressed if compress is set and the response can be encoded
+func (w *compressWriter) start(compress bool) error {
+	w.started = true
+	header := w.Header()
+	if compress && bodyAllowed(w.status) && header.Get("Content-Encoding") == "" {
+		header.Set("Content-Encoding", w.encoding)
+		header.Del("Content-Length")
+		if etag := header.Get("ETag"); etag != "" {
+			header.Set("ETag", weakETag(etag))
+		}
+		w.encoder = encoderPools[w.encoding].Get().(encoder)
+		w.encoder.Reset(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+
+	pending := w.pending
+	w.pending = nil
+	if len(pending) == 0 {
+		return nil
+	}
+	var err error
+	if w.encoder != nil {
+		_, err = w.encoder.Write(pending)
+	} else {
+		_, err = w.ResponseWriter.Write(pending)
+	}
+	return err
+}
+
+// finish sends the pending bytes of a small response uncompressed, or ends the compressed stream
+func (w *compressWriter) finish() error {
+	if !w.started {
+		return w.start(false)
+	}
+	if w.encoder == nil {
+		return nil
+	}
+	err := w.encoder.Close()
+	// release the response writer before returning the encoder to the pool
+	w.encoder.Reset(io.Discard)
+	encoderPools[w.encoding].Put(w.encoder)
+	w.encoder = nil
+	return err
+}
+
+// bodyAllowed reports whether a response with the status can have a body
+func bodyAllowed(status int) bool {
+	return status >= http.StatusOK && status != http.StatusNoContent && status != http.StatusNotModified
+}
@@ -0,0 +1,274 @@
+package api
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stakestar/startracker/db"
+	"go.uber.org/zap"
+)
+
+// reasons operators are grouped into one entity
+const (
+	reasonSharedOwner  = "shared_owner"
+	reasonSharedIP     = "shared_ip"
+	reasonSharedSubnet = "shared_subnet"
+)
+
+// subnet sizes operators sharing a network are grouped by
+const (
+	entitySubnetIPv4 = 24
+	entitySubnetIPv6 = 48
+)
+
+// entity is a group of operators likely controlled by the same party
+type entity struct {
+	ID        string           `json:"id"`
+	Operators []uint64         `json:"operators"`
+	Owners    []string         `json:"owners"`
+	Reasons   []string         `json:"reasons"`
+	Nodes     int              `json:"nodes"`
+	Share     float64          `json:"share"`
+	Locations []entityLocation `json:"locations"`
+	ASNs      []uint32         `json:"asns"`
+}
+
+type entityLocation struct {
+	CountryCode string `json:"country_code"`
+	City        string `json:"city"`
+	Nodes       int    `json:"nodes"`
+}
+
+// unionFind groups operator ids
+type unionFind map[uint64]uint64
+
+func (u unionFind) find(id uint64) uint64 {
+	parent, ok := u[id]
+	if !ok {
+		u[id] = id
+		return id
+	}
+	if parent == id {
+		return id
+	}
+	root := u.find(parent)
+	u[id] = root
+	return root
+}
+
+func (u unionFind) union(a, b uint64) {
+	rootA, rootB := u.find(a), u.find(b)
+	if rootA == rootB {
+		return
+	}
+	// the lowest operator id is the root so that entity ids are stable
+	if rootA < rootB {
+		u[rootB] = rootA
+	} else {
+		u[rootA] = rootB
+	}
+}
+
+// buildEntities clusters the operators by shared owner, ip or subnet,
+// nodes are the raw nodes used for grouping and located the privacy filtered nodes in the same order
+func buildEntities(operators []db.Operator, nodes, located []db.NodeData) []entity {
+	groups := make(unionFind)
+	// keys maps a shared attribute to the operators having it
+	keys := make(map[string]map[uint64]bool)
+	addKey := func(key string, operatorID uint64) {
+		if keys[key] == nil {
+			keys[key] = make(map[uint64]bool)
+		}
+		keys[key][operatorID] = true
+	}
+
+	owners := make(map[uint64]string)
+	for _, operator := range operators {
+		groups.find(operator.OperatorIDContract)
+		if operator.Owner != "" {
+			owner := strings.ToLower(operator.Owner)
+			owners[operator.OperatorIDContract] = operator.Owner
+			addKey(reasonSharedOwner+"|"+owner, operator.OperatorIDContract)
+		}
+	}
+	totalNodes := 0
+	for _, node := range nodes {
+		if node.OperatorIDContract == 0 {
+			continue
+		}
+		totalNodes++
+		groups.find(node.OperatorIDContract)
+		ip := net.ParseIP(node.IPAddress)
+		if ip == nil {
+			continue
+		}
+		addKey(reasonSharedIP+"|"+ip.String(), node.OperatorIDContract)
+		addKey(reasonSharedSubnet+"|"+subnetOf(ip), node.OperatorIDContract)
+	}
+
+	for _, members := range keys {
+		var first uint64
+		for operatorID := range members {
+			if first == 0 {
+				first = operatorID
+				continue
+			}
+			groups.union(first, operatorID)
+		}
+	}
+
+	entities := make(map[uint64]*entity)
+	entityOf := func(operatorID uint64) *entity {
+		root := groups.find(operatorID)
+		if entities[root] == nil {
+			entities[root] = &entity{ID: fmt.Sprintf("entity-%d", root)}
+		}
+		return entities[root]
+	}
+	for operatorID := range groups {
+		e := entityOf(operatorID)
+		e.Operators = append(e.Operators, operatorID)
+		if owner, ok := owners[operatorID]; ok {
+			e.Owners = appendUnique(e.Owners, owner)
+		}
+	}
+	for key, members := range keys {
+		if len(members) < 2 {
+			continue
+		}
+		reason := key[:strings.Index(key, "|")]
+		for operatorID := range members {
+			e := entityOf(operatorID)
+			e.Reasons = appendUnique(e.Reasons, reason)
+			break
+		}
+	}
+	locations := make(map[uint64]map[locationKey]int)
+	for i, node := range nodes {
+		if node.OperatorIDContract == 0 {
+			continue
+		}
+		e := entityOf(node.OperatorIDContract)
+		e.Nodes++
+		if node.GeoData.ASN != 0 && !containsASN(e.ASNs, node.GeoData.ASN) {
+			e.ASNs = append(e.ASNs, node.GeoData.ASN)
+		}
+		geoData := located[i].GeoData
+		if hasLocation(&geoData) {
+			root := groups.find(node.OperatorIDContract)
+			if locations[root] == nil {
+				locations[root] = make(map[locationKey]int)
+			}
+			locations[root][locationKey{geoData.CountryCode, geoData.City}]++
+		}
+	}
+
+	result := make([]entity, 0, len(entities))
+	for root, e := range entities {
+		sort.Slice(e.Operators, func(i, j int) bool { return e.Operators[i] < e.Operators[j] })
+		sort.Strings(e.Owners)
+		sort.Strings(e.Reasons)
+		sort.Slice(e.ASNs, func(i, j int) bool { return e.ASNs[i] < e.ASNs[j] })
+		if e.Owners == nil {
+			e.Owners = []string{}
+		}
+		if e.Reasons == nil {
+			e.Reasons = []string{}
+		}
+		if e.ASNs == nil {
+			e.ASNs = []uint32{}
+		}
+		e.Locations = []entityLocation{}
+		for location, count := range locations[root] {
+			e.Locations = append(e.Locations, entityLocation{CountryCode: location.countryCode, City: location.city, Nodes: count})
+		}
+		sort.Slice(e.Locations, func(i, j int) bool {
+			if e.Locations[i].Nodes != e.Locations[j].Nodes {
+				return e.Locations[i].Nodes > e.Locations[j].Nodes
+			}
+			if e.Locations[i].CountryCode != e.Locations[j].CountryCode {
+				return e.Locations[i].CountryCode < e.Locations[j].CountryCode
+			}
+			return e.Locations[i].City < e.Locations[j].City
+		})
+		if totalNodes > 0 {
+			e.Share = float64(e.Nodes) / float64(totalNodes)
+		}
+		result = append(result, *e)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Nodes != result[j].Nodes {
+			return result[i].Nodes > result[j].Nodes
+		}
+		return result[i].Operators[0] < result[j].Operators[0]
+	})
+	return result
+}
+
+func subnetOf(ip net.IP) string {
+	if ip4 := ip.To4(); ip4 != nil {
+		return (&net.IPNet{IP: ip4.Mask(net.CIDRMask(entitySubnetIPv4, 32)), Mask: net.CIDRMask(entitySubnetIPv4, 32)}).String()
+	}
+	return (&net.IPNet{IP: ip.Mask(net.CIDRMask(entitySubnetIPv6, 128)), Mask: net.CIDRMask(entitySubnetIPv6, 128)}).String()
+}
+
+func appendUnique(values []string, value string) []string {
+	for _, v := range values {
+		if v == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
+
+func containsASN(asns []uint32, asn uint32) bool {
+	for _, a := range asns {
+		if a == asn {
+			return true
+		}
+	}
+	return false
+}
+
+// entities groups all operators into entities
+func (api *Api) entities() ([]entity, error) {
+	operators, err := api.db.ListOperators()
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := api.allNodes()
+	if err != nil {
+		return nil, err
+	}
+	return buildEntities(operators, nodes, applyPrivacy(&api.config.Privacy, nodes)), nil
+}
+
+// GetEntities lists the operator groups with at least min_operators operators (default 2)
+func (api *Api) GetEntities(c *gin.Context) {
+	minOperators, err := strconv.Atoi(c.DefaultQuery("min_operators", "2"))
+	if err != nil || minOperators < 1 {
+		abort(c, http.StatusBadRequest, codeInvalidParameter, "min_operators must be a positive number")
+		return
+	}
+
+	entities, err := api.entities()
+	if err != nil {
+		api.logger.Error("Error building entities", zap.Error(err))
+		abortInternal(c)
+		return
+	}
+	filtered := make([]entity, 0, len(entities))
+	for _, e := range entities {
+		if len(e.Operators) >= minOperators {
+			filtered = append(filtered, e)
+		}
+	}
+	api.respondList(c, "entities", filtered, gin.H{
+		"count": len(filtered),
+	})
+}
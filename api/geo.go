@@ -0,0 +1,224 @@
+package api
+
+import (
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stakestar/startracker/db"
+	"go.uber.org/zap"
+)
+
+const (
+	earthRadiusKm = 6371.0088
+	// maxNearRadiusKm is half of the earth circumference, any radius above covers the whole earth
+	maxNearRadiusKm = math.Pi * earthRadiusKm
+)
+
+// nodeWithDistance is a node with its distance to the queried point
+type nodeWithDistance struct {
+	db.NodeData
+	DistanceKm float64 `json:"distance_km"`
+}
+
+// distanceKm returns the great circle distance between two points
+func distanceKm(lat1, lon1, lat2, lon2 float64) float64 {
+	toRadians := func(degrees float64) float64 { return degrees * math.Pi / 180 }
+	dLat := toRadians(lat2 - lat1)
+	dLon := toRadians(lon2 - lon1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRadians(lat1))*math.Cos(toRadians(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// geoBoxes returns the boxes covering the rectangle, split at the antimeridian when minLon is greater than maxLon
+func geoBoxes(minLat, minLon, maxLat, maxLon float64) []db.GeoBox {
+	if minLon <= maxLon {
+		return []db.GeoBox{{MinLatitude: minLat, MinLongitude: minLon, MaxLatitude: maxLat, MaxLongitude: maxLon}}
+	}
+	return []db.GeoBox{
+		{MinLatitude: minLat, MinLongitude: minLon, MaxLatitude: maxLat, MaxLongitude: 180},
+		{MinLatitude: minLat, MinLongitude: -180, MaxLatitude: maxLat, MaxLongitude: maxLon},
+	}
+}
+
+// boxesAround returns the boxes containing the circle of the radius around the point
+func boxesAround(lat, lon, radiusKm float64) []db.GeoBox {
+	angle := radiusKm / earthRadiusKm
+	latDelta := angle * 180 / math.Pi
+	minLat, maxLat := lat-latDelta, lat+latDelta
+	if minLat <= -90 || maxLat >= 90 {
+		// the circle contains a pole
+		return geoBoxes(math.Max(minLat, -90), -180, math.Min(maxLat, 90), 180)
+	}
+	lonDelta := math.Asin(math.Sin(angle)/math.Cos(lat*math.Pi/180)) * 180 / math.Pi
+	minLon, maxLon := lon-lonDelta, lon+lonDelta
+	if minLon < -180 {
+		minLon += 360
+	}
+	if maxLon > 180 {
+		maxLon -= 360
+	}
+	return geoBoxes(minLat, minLon, maxLat, maxLon)
+}
+
+// publicNodesInBoxes returns the nodes whose public location is in one of the boxes. When the privacy rules apply
+// to every node on its own only the indexed nodes around the boxes are read, grid snapping moves a node by less
+// than a cell so the boxes are widened by a cell. City centroids and k-anonymity depend on all nodes
+func (api *Api) publicNodesInBoxes(boxes []db.GeoBox) ([]db.NodeData, error) {
+	if !nodePrivacyOnly(&api.config.Privacy) {
+		nodes, err := api.publicNodes()
+		if err != nil {
+			return nil, err
+		}
+		return inBoxes(nodes, boxes), nil
+	}
+
+	margin := 0.0
+	if api.config.Privacy.CoordinateMode == CoordinateModeGrid {
+		margin = api.config.Privacy.GridSize
+	}
+	var matched []db.NodeData
+	seen := make(map[string]bool)
+	for _, box := range boxes {
+		boxNodes, err := api.db.ListNodesInBox(widen(box, margin))
+		if err != nil {
+			return nil, err
+		}
+		for _, node := range boxNodes {
+			if !seen[node.OperatorID] {
+				seen[node.OperatorID] = true
+				matched = append(matched, node)
+			}
+		}
+	}
+	matched, err := api.decorateNodes(matched, true)
+	if err != nil {
+		return nil, err
+	}
+	return inBoxes(matched, boxes), nil
+}
+
+// inBoxes returns the located nodes inside one of the boxes
+func inBoxes(nodes []db.NodeData, boxes []db.GeoBox) []db.NodeData {
+	var result []db.NodeData
+	for _, node := range nodes {
+		for _, box := range boxes {
+			if hasLocation(&node.GeoData) && box.Contains(node.GeoData.Latitude, node.GeoData.Longitude) {
+				result = append(result, node)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// widen returns the box grown by margin degrees on every side, within the coordinate bounds
+func widen(box db.GeoBox, margin float64) db.GeoBox {
+	if margin <= 0 {
+		return box
+	}
+	return db.GeoBox{
+		MinLatitude:  math.Max(box.MinLatitude-margin, -90),
+		MinLongitude: math.Max(box.MinLongitude-margin, -180),
+		MaxLatitude:  math.Min(box.MaxLatitude+margin, 90),
+		MaxLongitude: math.Min(box.MaxLongitude+margin, 180),
+	}
+}
+
+func parseCoordinate(c *gin.Context, name string, limit float64) (float64, bool) {
+	value, err := strconv.ParseFloat(c.Query(name), 64)
+	if err != nil || math.IsNaN(value) || value < -limit || value > limit {
+		abort(c, http.StatusBadRequest, codeInvalidParameter, "invalid "+name)
+		return 0, false
+	}
+	return value, true
+}
+
+func withDistances(nodes []db.NodeData, lat, lon float64) []nodeWithDistance {
+	result := make([]nodeWithDistance, 0, len(nodes))
+	for _, node := range nodes {
+		result = append(result, nodeWithDistance{
+			NodeData:   node,
+			DistanceKm: distanceKm(lat, lon, node.GeoData.Latitude, node.GeoData.Longitude),
+		})
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].DistanceKm < result[j].DistanceKm })
+	return result
+}
+
+func (api *Api) GetNodesNear(c *gin.Context) {
+	lat, ok := parseCoordinate(c, "lat", 90)
+	if !ok {
+		return
+	}
+	lon, ok := parseCoordinate(c, "lon", 180)
+	if !ok {
+		return
+	}
+	radius, err := strconv.ParseFloat(c.Query("radius"), 64)
+	if err != nil || !(radius > 0) {
+		abort(c, http.StatusBadRequest, codeInvalidParameter, "invalid radius")
+		return
+	}
+	radius = math.Min(radius, maxNearRadiusKm)
+
+	nodes, err := api.publicNodesInBoxes(boxesAround(lat, lon, radius))
+	if err != nil {
+		api.logger.Error("Error getting nodes near", zap.Error(err))
+		abortInternal(c)
+		return
+	}
+	near := make([]nodeWithDistance, 0, len(nodes))
+	for _, node := range withDistances(nodes, lat, lon) {
+		if node.DistanceKm <= radius {
+			near = append(near, node)
+		}
+	}
+	api.respondList(c, "nodes", near, gin.H{
+		"count":     len(near),
+		"lat":       lat,
+		"lon":       lon,
+		"radius_km": radius,
+	})
+}
+
+func (api *Api) GetNodesInBox(c *gin.Context) {
+	var bounds [4]float64
+	for i, param := range []struct {
+		name  string
+		limit float64
+	}{{"min_lat", 90}, {"min_lon", 180}, {"max_lat", 90}, {"max_lon", 180}} {
+		value, ok := parseCoordinate(c, param.name, param.limit)
+		if !ok {
+			return
+		}
+		bounds[i] = value
+	}
+	minLat, minLon, maxLat, maxLon := bounds[0], bounds[1], bounds[2], bounds[3]
+	if minLat > maxLat {
+		abort(c, http.StatusBadRequest, codeInvalidParameter, "min_lat is greater than max_lat")
+		return
+	}
+
+	nodes, err := api.publicNodesInBoxes(geoBoxes(minLat, minLon, maxLat, maxLon))
+	if err != nil {
+		api.logger.Error("Error getting nodes in box", zap.Error(err))
+		abortInternal(c)
+		return
+	}
+	// distances are measured from the center of the box
+	centerLat := (minLat + maxLat) / 2
+	centerLon := (minLon + maxLon) / 2
+	if minLon > maxLon {
+		centerLon = math.Mod(centerLon+360, 360) - 180
+	}
+	result := withDistances(nodes, centerLat, centerLon)
+	api.respondList(c, "nodes", result, gin.H{
+		"count":      len(result),
+		"center_lat": centerLat,
+		"center_lon": centerLon,
+	})
+}
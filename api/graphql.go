@@ -0,0 +1,259 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	ginlimiter "github.com/ulule/limiter/v3/drivers/middleware/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	graphqlPath = "/api/graphql"
+
+	// graphqlListSize is the estimated size of the lists without a limit argument
+	graphqlListSize = 10
+
+	codeQueryTooComplex = "query_too_complex"
+)
+
+type GraphQLConfig struct {
+	MaxComplexity        int `yaml:"MaxComplexity" env:"API_GRAPHQL_MAX_COMPLEXITY" env-default:"5000" env-description:"Maximum complexity of a GraphQL query"`
+	ComplexityPerRequest int `yaml:"ComplexityPerRequest" env:"API_GRAPHQL_COMPLEXITY_PER_REQUEST" env-default:"1000" env-description:"GraphQL query complexity charged as one more request to the rate limiter"`
+}
+
+type graphqlRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+func isGraphQL(c *gin.Context) bool {
+	return c.Request.URL.Path == graphqlPath
+}
+
+// graphqlErrors is a GraphQL response with the errors and their code
+func graphqlErrors(code string, errs []gqlerrors.FormattedError) *graphql.Result {
+	for i := range errs {
+		errs[i].Extensions = map[string]interface{}{"code": code}
+	}
+	return &graphql.Result{Errors: errs}
+}
+
+// GraphQL executes a read only query over the public data. On top of the request itself,
+// the query complexity is charged to the rate limit of the client
+func (api *Api) GraphQL(c *gin.Context) {
+	var request graphqlRequest
+	if c.Request.Method == http.MethodGet {
+		request.Query = c.Query("query")
+		request.OperationName = c.Query("operationName")
+		if variables := c.Query("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &request.Variables); err != nil {
+				abort(c, http.StatusBadRequest, codeInvalidParameter, "invalid variables")
+				return
+			}
+		}
+	} else if err := c.ShouldBindJSON(&request); err != nil {
+		abort(c, http.StatusBadRequest, codeInvalidParameter, "invalid request")
+		return
+	}
+	if request.Query == "" {
+		abort(c, http.StatusBadRequest, codeInvalidParameter, "query is required")
+		return
+	}
+
+	document, err := parser.Parse(parser.ParseParams{Source: request.Query})
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, graphqlErrors(codeInvalidParameter, gqlerrors.FormatErrors(err)))
+		return
+	}
+	validation := graphql.ValidateDocument(&api.schema, document, nil)
+	if !validation.IsValid {
+		c.AbortWithStatusJSON(http.StatusBadRequest, graphqlErrors(codeInvalidParameter, validation.Errors))
+		return
+	}
+
+	complexity := queryComplexity(&api.schema, document, request.OperationName, request.Variables)
+	if complexity > api.config.GraphQL.MaxComplexity {
+		abort(c, http.StatusBadRequest, codeQueryTooComplex, fmt.Sprintf("query complexity %d exceeds the limit of %d", complexity, api.config.GraphQL.MaxComplexity))
+		return
+	}
+	if !api.chargeComplexity(c, complexity) {
+		return
+	}
+
+	result := graphql.Execute(graphql.ExecuteParams{
+		Schema:        api.schema,
+		AST:           document,
+		OperationName: request.OperationName,
+		Args:          request.Variables,
+		Context:       context.WithValue(c.Request.Context(), graphqlDataKey{}, &graphqlData{api: api}),
+	})
+	result.Extensions = map[string]interface{}{"complexity": complexity}
+	c.JSON(http.StatusOK, result)
+}
+
+// chargeComplexity counts one more request to the rate limiter per ComplexityPerRequest points of the query
+func (api *Api) chargeComplexity(c *gin.Context, complexity int) bool {
+	if api.limiter == nil || api.config.GraphQL.ComplexityPerRequest <= 0 {
+		return true
+	}
+	count := int64(complexity / api.config.GraphQL.ComplexityPerRequest)
+	if count == 0 {
+		return true
+	}
+	limit, err := api.limiter.Increment(c.Request.Context(), ginlimiter.DefaultKeyGetter(c), count)
+	if err != nil {
+		api.logger.Error("Error charging query complexity", zap.Error(err))
+		abortInternal(c)
+		return false
+	}
+	c.Header("X-RateLimit-Remaining", strconv.FormatInt(limit.Remaining, 10))
+	if limit.Reached {
+		abort(c, http.StatusTooManyRequests, codeRateLimited, "rate limit exceeded")
+		return false
+	}
+	return true
+}
+
+// complexityWalker sums the cost of the fields selected by a validated query
+type complexityWalker struct {
+	schema    *graphql.Schema
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]interface{}
+}
+
+// queryComplexity estimates the cost of the operation, every field costs 1 and the fields selected under a list
+// are multiplied by its limit argument, or graphqlListSize for the lists without one
+func queryComplexity(schema *graphql.Schema, document *ast.Document, operationName string, variables map[string]interface{}) int {
+	walker := &complexityWalker{
+		schema:    schema,
+		fragments: make(map[string]*ast.FragmentDefinition),
+		variables: make(map[string]interface{}, len(variables)),
+	}
+	for name, value := range variables {
+		walker.variables[name] = value
+	}
+	var operation *ast.OperationDefinition
+	for _, definition := range document.Definitions {
+		switch definition := definition.(type) {
+		case *ast.FragmentDefinition:
+			walker.fragments[definition.Name.Value] = definition
+		case *ast.OperationDefinition:
+			if operation == nil && (operationName == "" || definition.Name != nil && definition.Name.Value == operationName) {
+				operation = definition
+			}
+		}
+	}
+	if operation == nil {
+		return 0
+	}
+	for _, definition := range operation.VariableDefinitions {
+		name := definition.Variable.Name.Value
+		if value, ok := definition.DefaultValue.(*ast.IntValue); ok && walker.variables[name] == nil {
+			size, _ := strconv.Atoi(value.Value)
+			walker.variables[name] = float64(size)
+		}
+	}
+	return walker.selections(schema.QueryType(), operation.SelectionSet)
+}
+
+// selections is the cost of a selection set of the parent type, parent is nil for the introspection types
+func (w *complexityWalker) selections(parent *graphql.Object, selectionSet *ast.SelectionSet) int {
+	if selectionSet == nil {
+		return 0
+	}
+	cost := 0
+	for _, selection := range selectionSet.Selections {
+		switch selection := selection.(type) {
+		case *ast.Field:
+			cost += w.field(parent, selection)
+		case *ast.InlineFragment:
+			cost += w.selections(w.fragmentType(parent, selection.TypeCondition), selection.SelectionSet)
+		case *ast.FragmentSpread:
+			if fragment, ok := w.fragments[selection.Name.Value]; ok {
+				cost += w.selections(w.fragmentType(parent, fragment.TypeCondition), fragment.SelectionSet)
+			}
+		}
+	}
+	return cost
+}
+
+func (w *complexityWalker) field(parent *graphql.Object, field *ast.Field) int {
+	if field.SelectionSet == nil {
+		return 1
+	}
+	var definition *graphql.FieldDefinition
+	if parent != nil {
+		definition = parent.Fields()[field.Name.Value]
+	}
+	if definition == nil {
+		return 1 + w.selections(nil, field.SelectionSet)
+	}
+
+	size := 1
+	fieldType := definition.Type
+	if nonNull, ok := fieldType.(*graphql.NonNull); ok {
+		fieldType = nonNull.OfType
+	}
+	if list, ok := fieldType.(*graphql.List); ok {
+		size = w.listSize(definition, field)
+		fieldType = list.OfType
+		if nonNull, ok := fieldType.(*graphql.NonNull); ok {
+			fieldType = nonNull.OfType
+		}
+	}
+	object, _ := fieldType.(*graphql.Object)
+	return 1 + size*w.selections(object, field.SelectionSet)
+}
+
+// listSize is the limit argument of a list field, its default if not given, or graphqlListSize
+func (w *complexityWalker) listSize(definition *graphql.FieldDefinition, field *ast.Field) int {
+	size := -1
+	for _, argument := range definition.Args {
+		if argument.Name() == "limit" {
+			size, _ = argument.DefaultValue.(int)
+		}
+	}
+	if size < 0 {
+		return graphqlListSize
+	}
+	for _, argument := range field.Arguments {
+		if argument.Name.Value != "limit" {
+			continue
+		}
+		switch value := argument.Value.(type) {
+		case *ast.IntValue:
+			size, _ = strconv.Atoi(value.Value)
+		case *ast.Variable:
+			// json numbers are decoded as float64
+			if variable, ok := w.variables[value.Name.Value].(float64); ok {
+				size = int(variable)
+			}
+		}
+	}
+	if size < 1 {
+		return 1
+	}
+	if size > maxPageLimit {
+		return maxPageLimit
+	}
+	return size
+}
+
+// fragmentType is the type a fragment applies to, the parent type without a type condition
+func (w *complexityWalker) fragmentType(parent *graphql.Object, condition *ast.Named) *graphql.Object {
+	if condition == nil {
+		return parent
+	}
+	object, _ := w.schema.Type(condition.Name.Value).(*graphql.Object)
+	return object
+}
@@ -0,0 +1,462 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/graphql-go/graphql"
+	"github.com/stakestar/startracker/db"
+	"github.com/stakestar/startracker/versions"
+	"go.uber.org/zap"
+)
+
+var errGraphQLInternal = errors.New("internal server error")
+
+type graphqlDataKey struct{}
+
+// graphqlData is the public data a GraphQL request resolves from, loaded once on first use
+type graphqlData struct {
+	api *Api
+
+	once      sync.Once
+	err       error
+	nodes     []db.NodeData
+	operators []operatorResponse
+	entities  []entity
+	// byOperatorID indexes operators by contract id
+	byOperatorID map[uint64]*operatorResponse
+}
+
+func (d *graphqlData) load() error {
+	d.once.Do(func() {
+		nodes, err := d.api.allNodes()
+		if err != nil {
+			d.api.logger.Error("Error getting nodes", zap.Error(err))
+			d.err = errGraphQLInternal
+			return
+		}
+		operators, err := d.api.db.ListOperators()
+		if err != nil {
+			d.api.logger.Error("Error getting operators", zap.Error(err))
+			d.err = errGraphQLInternal
+			return
+		}
+		d.nodes = applyPrivacy(&d.api.config.Privacy, nodes)
+		d.operators = d.api.joinOperators(operators, d.nodes)
+		d.entities = buildEntities(operators, nodes, d.nodes)
+		d.byOperatorID = make(map[uint64]*operatorResponse, len(d.operators))
+		for i := range d.operators {
+			d.byOperatorID[d.operators[i].OperatorID] = &d.operators[i]
+		}
+	})
+	return d.err
+}
+
+// loadedData returns the data of the request, loaded
+func loadedData(ctx context.Context) (*graphqlData, error) {
+	data := ctx.Value(graphqlDataKey{}).(*graphqlData)
+	return data, data.load()
+}
+
+// pageArgs reads and validates the offset and limit arguments of a list field
+func pageArgs(p graphql.ResolveParams) (offset, limit int, err error) {
+	offset, _ = p.Args["offset"].(int)
+	limit, _ = p.Args["limit"].(int)
+	if offset < 0 {
+		return 0, 0, errors.New("offset must be a non negative number")
+	}
+	if limit < 1 || limit > maxPageLimit {
+		return 0, 0, errors.New("limit must be between 1 and " + strconv.Itoa(maxPageLimit))
+	}
+	return offset, limit, nil
+}
+
+func pageFieldArgs(args graphql.FieldConfigArgument) graphql.FieldConfigArgument {
+	args["offset"] = &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 0}
+	args["limit"] = &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: defaultPageLimit, Description: "At most " + strconv.Itoa(maxPageLimit)}
+	return args
+}
+
+// operatorInfoField resolves a field of the contract data embedded in an operatorResponse
+func operatorInfoField(fieldType graphql.Output) *graphql.Field {
+	return &graphql.Field{
+		Type: fieldType,
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			p.Source = p.Source.(*operatorResponse).operatorInfo
+			return graphql.DefaultResolveFn(p)
+		},
+	}
+}
+
+func nonNullList(of graphql.Type) *graphql.NonNull {
+	return graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(of)))
+}
+
+// newGraphQLSchema builds the read only schema over the public nodes, operators, entities, stats and versions
+func newGraphQLSchema() (graphql.Schema, error) {
+	geoDataType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "GeoData",
+		Fields: graphql.Fields{
+			"country_code":    &graphql.Field{Type: graphql.String},
+			"country_name":    &graphql.Field{Type: graphql.String},
+			"city":            &graphql.Field{Type: graphql.String},
+			"latitude":        &graphql.Field{Type: graphql.Float},
+			"longitude":       &graphql.Field{Type: graphql.Float},
+			"accuracy_radius": &graphql.Field{Type: graphql.Int},
+			// ASNs are 32 bit unsigned, out of the GraphQL Int range
+			"asn":          &graphql.Field{Type: graphql.Float},
+			"organization": &graphql.Field{Type: graphql.String},
+			"provider":     &graphql.Field{Type: graphql.String},
+		},
+	})
+
+	locationClaimType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "LocationClaim",
+		Fields: graphql.Fields{
+			"operator_id":  &graphql.Field{Type: graphql.Int},
+			"country_code": &graphql.Field{Type: graphql.String},
+			"city":         &graphql.Field{Type: graphql.String},
+			"latitude":     &graphql.Field{Type: graphql.Float},
+			"longitude":    &graphql.Field{Type: graphql.Float},
+			"timestamp":    &graphql.Field{Type: graphql.Int},
+			"signature":    &graphql.Field{Type: graphql.String},
+			"verified":     &graphql.Field{Type: graphql.Boolean, Description: "Whether the signature matches the current operator key"},
+		},
+	})
+
+	feeChangeType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "FeeChange",
+		Fields: graphql.Fields{
+			"type":  &graphql.Field{Type: graphql.String},
+			"block": &graphql.Field{Type: graphql.Int},
+			"fee":   &graphql.Field{Type: graphql.String, Description: "Wei per block"},
+		},
+	})
+
+	operatorStatusType := graphql.NewEnum(graphql.EnumConfig{
+		Name: "OperatorStatus",
+		Values: graphql.EnumValueConfigMap{
+			operatorStatusOnline:  &graphql.EnumValueConfig{Value: operatorStatusOnline},
+			operatorStatusOffline: &graphql.EnumValueConfig{Value: operatorStatusOffline},
+			operatorStatusNotSeen: &graphql.EnumValueConfig{Value: operatorStatusNotSeen},
+		},
+	})
+
+	// nodes and operators reference each other, their fields are built lazily
+	var nodeType, operatorType *graphql.Object
+	nodeType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Node",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"operator_id":    &graphql.Field{Type: graphql.Int},
+				"updated_at":     &graphql.Field{Type: graphql.DateTime},
+				"ip_address":     &graphql.Field{Type: graphql.String},
+				"address_family": &graphql.Field{Type: graphql.String},
+				"geo_data":       &graphql.Field{Type: geoDataType},
+				"claimed_geo":    &graphql.Field{Type: locationClaimType},
+				"node_version":   &graphql.Field{Type: graphql.String},
+				"outdated":       &graphql.Field{Type: graphql.Boolean},
+				"operator": &graphql.Field{
+					Type: operatorType,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						data, err := loadedData(p.Context)
+						if err != nil {
+							return nil, err
+						}
+						if operator, ok := data.byOperatorID[p.Source.(*db.NodeData).OperatorIDContract]; ok {
+							return operator, nil
+						}
+						return nil, nil
+					},
+				},
+			}
+		}),
+	})
+	operatorType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Operator",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"operator_id":        operatorInfoField(graphql.Int),
+				"public_key":         operatorInfoField(graphql.String),
+				"owner":              operatorInfoField(graphql.String),
+				"fee":                operatorInfoField(graphql.String),
+				"declared_fee":       operatorInfoField(graphql.String),
+				"fee_history":        operatorInfoField(nonNullList(feeChangeType)),
+				"registration_block": operatorInfoField(graphql.Int),
+				"status":             &graphql.Field{Type: operatorStatusType},
+				"node":               &graphql.Field{Type: nodeType},
+			}
+		}),
+	})
+
+	entityLocationType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "EntityLocation",
+		Fields: graphql.Fields{
+			"country_code": &graphql.Field{Type: graphql.String},
+			"city":         &graphql.Field{Type: graphql.String},
+			"nodes":        &graphql.Field{Type: graphql.Int},
+		},
+	})
+
+	entityType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Entity",
+		Fields: graphql.Fields{
+			"id": &graphql.Field{Type: graphql.String},
+			"operator_ids": &graphql.Field{
+				Type: nonNullList(graphql.Int),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*entity).Operators, nil
+				},
+			},
+			"operators": &graphql.Field{
+				Type: nonNullList(operatorType),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					data, err := loadedData(p.Context)
+					if err != nil {
+						return nil, err
+					}
+					ids := p.Source.(*entity).Operators
+					operators := make([]*operatorResponse, 0, len(ids))
+					for _, id := range ids {
+						if operator, ok := data.byOperatorID[id]; ok {
+							operators = append(operators, operator)
+						}
+					}
+					return operators, nil
+				},
+			},
+			"owners":    &graphql.Field{Type: nonNullList(graphql.String)},
+			"reasons":   &graphql.Field{Type: nonNullList(graphql.String)},
+			"nodes":     &graphql.Field{Type: graphql.Int},
+			"share":     &graphql.Field{Type: graphql.Float},
+			"locations": &graphql.Field{Type: nonNullList(entityLocationType)},
+			"asns":      &graphql.Field{Type: nonNullList(graphql.Float)},
+		},
+	})
+
+	concentrationType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Concentration",
+		Fields: graphql.Fields{
+			"groups":    &graphql.Field{Type: graphql.Int},
+			"nakamoto":  &graphql.Field{Type: graphql.Int, Description: "Smallest number of groups running more than a third of the nodes"},
+			"hhi":       &graphql.Field{Type: graphql.Float, Description: "Herfindahl-Hirschman index between 0 and 1"},
+			"top_share": &graphql.Field{Type: graphql.Float},
+		},
+	})
+
+	statsType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Stats",
+		Fields: graphql.Fields{
+			"nodes":     &graphql.Field{Type: graphql.Int},
+			"operators": &graphql.Field{Type: graphql.Int},
+			"entities":  &graphql.Field{Type: concentrationType},
+			"countries": &graphql.Field{Type: concentrationType},
+			"asns":      &graphql.Field{Type: concentrationType},
+		},
+	})
+
+	versionCountType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "VersionCount",
+		Fields: graphql.Fields{
+			"version":  &graphql.Field{Type: graphql.String},
+			"count":    &graphql.Field{Type: graphql.Int},
+			"share":    &graphql.Field{Type: graphql.Float},
+			"outdated": &graphql.Field{Type: graphql.Boolean},
+		},
+	})
+
+	versionAdoptionType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "VersionAdoption",
+		Fields: graphql.Fields{
+			"date":         &graphql.Field{Type: graphql.String, Description: "YYYY-MM-DD, empty for the current distribution"},
+			"total":        &graphql.Field{Type: graphql.Int},
+			"latest_share": &graphql.Field{Type: graphql.Float},
+			"versions":     &graphql.Field{Type: nonNullList(versionCountType)},
+		},
+	})
+
+	versionsType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Versions",
+		Fields: graphql.Fields{
+			"latest":  &graphql.Field{Type: graphql.String},
+			"current": &graphql.Field{Type: versionAdoptionType},
+			"history": &graphql.Field{Type: nonNullList(versionAdoptionType)},
+		},
+	})
+
+	queryType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"nodes": &graphql.Field{
+				Type:        nonNullList(nodeType),
+				Description: "Public nodes, only the ones with a known operator unless all is set",
+				Args: pageFieldArgs(graphql.FieldConfigArgument{
+					"country":  &graphql.ArgumentConfig{Type: graphql.String, Description: "ISO country code"},
+					"version":  &graphql.ArgumentConfig{Type: graphql.String},
+					"outdated": &graphql.ArgumentConfig{Type: graphql.Boolean},
+					"all":      &graphql.ArgumentConfig{Type: graphql.Boolean, DefaultValue: false},
+				}),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					offset, limit, err := pageArgs(p)
+					if err != nil {
+						return nil, err
+					}
+					data, err := loadedData(p.Context)
+					if err != nil {
+						return nil, err
+					}
+					country, _ := p.Args["country"].(string)
+					version, _ := p.Args["version"].(string)
+					outdated, filterOutdated := p.Args["outdated"].(bool)
+					all, _ := p.Args["all"].(bool)
+
+					matched := make([]*db.NodeData, 0)
+					for i := range data.nodes {
+						node := &data.nodes[i]
+						if !all && node.OperatorIDContract == 0 {
+							continue
+						}
+						if country != "" && !strings.EqualFold(node.GeoData.CountryCode, country) {
+							continue
+						}
+						if version != "" && versions.Canonical(node.NodeVersion) != versions.Canonical(version) {
+							continue
+						}
+						if filterOutdated && node.Outdated != outdated {
+							continue
+						}
+						matched = append(matched, node)
+					}
+					start, end := page(offset, limit, len(matched))
+					return matched[start:end], nil
+				},
+			},
+			"node": &graphql.Field{
+				Type: nodeType,
+				Args: graphql.FieldConfigArgument{
+					"operator_id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					data, err := loadedData(p.Context)
+					if err != nil {
+						return nil, err
+					}
+					operatorID := uint64(p.Args["operator_id"].(int))
+					for i := range data.nodes {
+						if data.nodes[i].OperatorIDContract == operatorID {
+							return &data.nodes[i], nil
+						}
+					}
+					return nil, nil
+				},
+			},
+			"operators": &graphql.Field{
+				Type:        nonNullList(operatorType),
+				Description: "Registered operators, country filters on the location of their node",
+				Args: pageFieldArgs(graphql.FieldConfigArgument{
+					"status":  &graphql.ArgumentConfig{Type: operatorStatusType},
+					"country": &graphql.ArgumentConfig{Type: graphql.String, Description: "ISO country code"},
+				}),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					offset, limit, err := pageArgs(p)
+					if err != nil {
+						return nil, err
+					}
+					data, err := loadedData(p.Context)
+					if err != nil {
+						return nil, err
+					}
+					status, _ := p.Args["status"].(string)
+					country, _ := p.Args["country"].(string)
+
+					matched := make([]*operatorResponse, 0)
+					for i := range data.operators {
+						operator := &data.operators[i]
+						if status != "" && operator.Status != status {
+							continue
+						}
+						if country != "" && (operator.Node == nil || !strings.EqualFold(operator.Node.GeoData.CountryCode, country)) {
+							continue
+						}
+						matched = append(matched, operator)
+					}
+					start, end := page(offset, limit, len(matched))
+					return matched[start:end], nil
+				},
+			},
+			"operator": &graphql.Field{
+				Type: operatorType,
+				Args: graphql.FieldConfigArgument{
+					"operator_id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					data, err := loadedData(p.Context)
+					if err != nil {
+						return nil, err
+					}
+					if operator, ok := data.byOperatorID[uint64(p.Args["operator_id"].(int))]; ok {
+						return operator, nil
+					}
+					return nil, nil
+				},
+			},
+			"entities": &graphql.Field{
+				Type:        nonNullList(entityType),
+				Description: "Groups of at least min_operators operators likely controlled by the same party",
+				Args: pageFieldArgs(graphql.FieldConfigArgument{
+					"min_operators": &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 2},
+				}),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					offset, limit, err := pageArgs(p)
+					if err != nil {
+						return nil, err
+					}
+					minOperators, _ := p.Args["min_operators"].(int)
+					if minOperators < 1 {
+						return nil, errors.New("min_operators must be a positive number")
+					}
+					data, err := loadedData(p.Context)
+					if err != nil {
+						return nil, err
+					}
+
+					matched := make([]*entity, 0)
+					for i := range data.entities {
+						if len(data.entities[i].Operators) >= minOperators {
+							matched = append(matched, &data.entities[i])
+						}
+					}
+					start, end := page(offset, limit, len(matched))
+					return matched[start:end], nil
+				},
+			},
+			"stats": &graphql.Field{
+				Type: graphql.NewNonNull(statsType),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					data, err := loadedData(p.Context)
+					if err != nil {
+						return nil, err
+					}
+					return newStats(data.entities, data.nodes), nil
+				},
+			},
+			"versions": &graphql.Field{
+				Type: graphql.NewNonNull(versionsType),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					data := p.Context.Value(graphqlDataKey{}).(*graphqlData)
+					report, err := data.api.versionsReport()
+					if err != nil {
+						data.api.logger.Error("Error building versions report", zap.Error(err))
+						return nil, errGraphQLInternal
+					}
+					return report, nil
+				},
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: queryType})
+}
@@ -0,0 +1,166 @@
+package api
+
+import (
+	"net"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stakestar/startracker/db"
+	"go.uber.org/zap"
+)
+
+// colocatedHost is an ip announced by the nodes of several operators
+type colocatedHost struct {
+	IPAddress   string   `json:"ip_address"`
+	OperatorIDs []uint64 `json:"operator_ids"`
+}
+
+// colocatedHosts groups the nodes by ip and returns the ips shared by more than one node
+func colocatedHosts(nodes []db.NodeData) []colocatedHost {
+	byIP := make(map[string][]uint64)
+	for _, node := range nodes {
+		if node.IPAddress != "" {
+			byIP[node.IPAddress] = append(byIP[node.IPAddress], node.OperatorIDContract)
+		}
+	}
+	hosts := make([]colocatedHost, 0)
+	for ip, operatorIDs := range byIP {
+		if len(operatorIDs) < 2 {
+			continue
+		}
+		sort.Slice(operatorIDs, func(i, j int) bool { return operatorIDs[i] < operatorIDs[j] })
+		hosts = append(hosts, colocatedHost{IPAddress: ip, OperatorIDs: operatorIDs})
+	}
+	sort.Slice(hosts, func(i, j int) bool { return hosts[i].IPAddress < hosts[j].IPAddress })
+	return hosts
+}
+
+func (api *Api) respondIPNodes(c *gin.Context, nodes []db.NodeData) {
+	api.respondList(c, "nodes", nodes, gin.H{
+		"count":     len(nodes),
+		"colocated": colocatedHosts(nodes),
+	})
+}
+
+// ipLookupAllowed rejects public ip lookups when ips are private, since they would reveal the ip of a node
+func (api *Api) ipLookupAllowed(c *gin.Context) bool {
+	if api.config.Privacy.HideIP {
+		abort(c, http.StatusForbidden, codeAdminRequired, "ip lookups require admin access")
+		return false
+	}
+	return true
+}
+
+func parseCIDR(c *gin.Context) (*net.IPNet, bool) {
+	_, network, err := net.ParseCIDR(c.Query("cidr"))
+	if err != nil {
+		abort(c, http.StatusBadRequest, codeInvalidParameter, "invalid cidr")
+		return nil, false
+	}
+	return network, true
+}
+
+// nodesByIP looks up the nodes of the ip path parameter
+func (api *Api) nodesByIP(c *gin.Context) ([]db.NodeData, bool) {
+	ip := net.ParseIP(c.Param("ip"))
+	if ip == nil {
+		abort(c, http.StatusBadRequest, codeInvalidParameter, "invalid ip")
+		return nil, false
+	}
+	nodes, err := api.db.ListNodesByIP(ip)
+	if err != nil {
+		api.logger.Error("Error getting nodes by ip", zap.Error(err))
+		abortInternal(c)
+		return nil, false
+	}
+	return nodes, true
+}
+
+// nodesInCIDR looks up the nodes in the cidr query parameter
+func (api *Api) nodesInCIDR(c *gin.Context) ([]db.NodeData, bool) {
+	network, ok := parseCIDR(c)
+	if !ok {
+		return nil, false
+	}
+	nodes, err := api.db.ListNodesInCIDR(network)
+	if err != nil {
+		api.logger.Error("Error getting nodes in cidr", zap.Error(err))
+		abortInternal(c)
+		return nil, false
+	}
+	return nodes, true
+}
+
+// restrictTo returns the nodes of all whose operator id is in matched, keeping the order of matched
+func restrictTo(all []db.NodeData, matched []db.NodeData) []db.NodeData {
+	byID := make(map[string]db.NodeData, len(all))
+	for _, node := range all {
+		byID[node.OperatorID] = node
+	}
+	result := make([]db.NodeData, 0, len(matched))
+	for _, node := range matched {
+		if n, ok := byID[node.OperatorID]; ok {
+			result = append(result, n)
+		}
+	}
+	return result
+}
+
+func (api *Api) GetNodesByIP(c *gin.Context) {
+	if !api.ipLookupAllowed(c) {
+		return
+	}
+	matched, ok := api.nodesByIP(c)
+	if !ok {
+		return
+	}
+	api.respondPublicIPNodes(c, matched, false)
+}
+
+func (api *Api) AdminGetNodesByIP(c *gin.Context) {
+	matched, ok := api.nodesByIP(c)
+	if !ok {
+		return
+	}
+	api.respondAdminIPNodes(c, matched)
+}
+
+// respondPublicIPNodes responds with the matched nodes with the privacy rules applied, all nodes are only
+// loaded when the privacy rules depend on the full node set
+func (api *Api) respondPublicIPNodes(c *gin.Context, matched []db.NodeData, onlyWithOperatorId bool) {
+	var nodes []db.NodeData
+	var err error
+	if nodePrivacyOnly(&api.config.Privacy) {
+		nodes, err = api.decorateNodes(matched, true)
+	} else {
+		nodes, err = api.publicNodes()
+		nodes = restrictTo(nodes, matched)
+	}
+	if err != nil {
+		api.logger.Error("Error getting nodes", zap.Error(err))
+		abortInternal(c)
+		return
+	}
+	if onlyWithOperatorId {
+		withOperatorId := make([]db.NodeData, 0, len(nodes))
+		for _, node := range nodes {
+			if node.OperatorIDContract != 0 {
+				withOperatorId = append(withOperatorId, node)
+			}
+		}
+		nodes = withOperatorId
+	}
+	api.respondIPNodes(c, nodes)
+}
+
+// respondAdminIPNodes responds with the matched nodes with their outdated flag set
+func (api *Api) respondAdminIPNodes(c *gin.Context, matched []db.NodeData) {
+	nodes, err := api.decorateNodes(matched, false)
+	if err != nil {
+		api.logger.Error("Error getting nodes", zap.Error(err))
+		abortInternal(c)
+		return
+	}
+	api.respondIPNodes(c, nodes)
+}
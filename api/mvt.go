@@ -0,0 +1,119 @@
+package api
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const (
+	mvtContentType = "application/vnd.mapbox-vector-tile"
+	mvtExtent      = 4096
+	mvtLayerName   = "clusters"
+	// maxMercatorLatitude is the latitude of the edges of the web mercator map
+	maxMercatorLatitude = 85.0511287798
+)
+
+// tilePoint returns the position of the point in the web mercator tile x/y of the zoom level, in tile extent units
+func tilePoint(latitude, longitude float64, zoom, x, y int) (int64, int64) {
+	latitude = math.Max(-maxMercatorLatitude, math.Min(maxMercatorLatitude, latitude))
+	n := math.Exp2(float64(zoom))
+	latRadians := latitude * math.Pi / 180
+	worldX := (longitude + 180) / 360 * n
+	worldY := (1 - math.Log(math.Tan(latRadians)+1/math.Cos(latRadians))/math.Pi) / 2 * n
+	return int64(math.Floor((worldX - float64(x)) * mvtExtent)), int64(math.Floor((worldY - float64(y)) * mvtExtent))
+}
+
+// encodeClusterTile encodes the clusters inside the tile as a mapbox vector tile with a single point layer,
+// features have the count and geohash properties
+func encodeClusterTile(clusters []cluster, zoom, x, y int) []byte {
+	var layer []byte
+	layer = protowire.AppendTag(layer, 15, protowire.VarintType)
+	layer = protowire.AppendVarint(layer, 2)
+	layer = protowire.AppendTag(layer, 1, protowire.BytesType)
+	layer = protowire.AppendString(layer, mvtLayerName)
+
+	var values []byte
+	valueIndex := uint64(0)
+	for _, cl := range clusters {
+		px, py := tilePoint(cl.Latitude, cl.Longitude, zoom, x, y)
+		if px < 0 || px >= mvtExtent || py < 0 || py >= mvtExtent {
+			continue
+		}
+
+		var tags []byte
+		tags = protowire.AppendVarint(tags, 0)
+		tags = protowire.AppendVarint(tags, valueIndex)
+		tags = protowire.AppendVarint(tags, 1)
+		tags = protowire.AppendVarint(tags, valueIndex+1)
+		valueIndex += 2
+
+		var geometry []byte
+		// a single MoveTo command followed by the zigzag encoded position
+		geometry = protowire.AppendVarint(geometry, 1&0x7|1<<3)
+		geometry = protowire.AppendVarint(geometry, protowire.EncodeZigZag(px))
+		geometry = protowire.AppendVarint(geometry, protowire.EncodeZigZag(py))
+
+		var feature []byte
+		feature = protowire.AppendTag(feature, 2, protowire.BytesType)
+		feature = protowire.AppendBytes(feature, tags)
+		feature = protowire.AppendTag(feature, 3, protowire.VarintType)
+		feature = protowire.AppendVarint(feature, 1) // POINT
+		feature = protowire.AppendTag(feature, 4, protowire.BytesType)
+		feature = protowire.AppendBytes(feature, geometry)
+
+		layer = protowire.AppendTag(layer, 2, protowire.BytesType)
+		layer = protowire.AppendBytes(layer, feature)
+
+		var count, hash []byte
+		count = protowire.AppendTag(count, 5, protowire.VarintType)
+		count = protowire.AppendVarint(count, uint64(cl.Count))
+		hash = protowire.AppendTag(hash, 1, protowire.BytesType)
+		hash = protowire.AppendString(hash, cl.Geohash)
+		values = protowire.AppendTag(values, 4, protowire.BytesType)
+		values = protowire.AppendBytes(values, count)
+		values = protowire.AppendTag(values, 4, protowire.BytesType)
+		values = protowire.AppendBytes(values, hash)
+	}
+
+	layer = protowire.AppendTag(layer, 3, protowire.BytesType)
+	layer = protowire.AppendString(layer, "count")
+	layer = protowire.AppendTag(layer, 3, protowire.BytesType)
+	layer = protowire.AppendString(layer, "geohash")
+	layer = append(layer, values...)
+	layer = protowire.AppendTag(layer, 5, protowire.VarintType)
+	layer = protowire.AppendVarint(layer, mvtExtent)
+
+	var tile []byte
+	tile = protowire.AppendTag(tile, 3, protowire.BytesType)
+	tile = protowire.AppendBytes(tile, layer)
+	return tile
+}
+
+// GetClusterTile serves the clusters of a zoom level as the mapbox vector tile /api/tiles/{z}/{x}/{y}.mvt
+func (api *Api) GetClusterTile(c *gin.Context) {
+	zoom, ok := parseZoom(c.Param("z"))
+	if !ok {
+		abort(c, http.StatusBadRequest, codeInvalidParameter, "invalid zoom")
+		return
+	}
+	x, errX := strconv.Atoi(c.Param("x"))
+	y, errY := strconv.Atoi(strings.TrimSuffix(c.Param("y"), ".mvt"))
+	if errX != nil || errY != nil || x < 0 || y < 0 || x >= 1<<zoom || y >= 1<<zoom {
+		abort(c, http.StatusBadRequest, codeInvalidParameter, "invalid tile")
+		return
+	}
+
+	clusters, err := api.clustersAt(zoom)
+	if err != nil {
+		api.logger.Error("Error building clusters", zap.Error(err))
+		abortInternal(c)
+		return
+	}
+	c.Data(http.StatusOK, mvtContentType, encodeClusterTile(clusters, zoom, x, y))
+}
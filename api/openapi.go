@@ -0,0 +1,17 @@
+package api
+
+import (
+	_ "embed"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// openAPISpec documents every route of Start, including the GraphQL endpoint outside /api/v1, it must be updated with the routes
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+func (api *Api) GetOpenAPI(c *gin.Context) {
+	c.Data(http.StatusOK, "application/json", openAPISpec)
+}
@@ -0,0 +1,179 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stakestar/startracker/db"
+	"github.com/stakestar/startracker/utils"
+	"go.uber.org/zap"
+)
+
+// operator statuses
+const (
+	operatorStatusOnline  = "online"
+	operatorStatusOffline = "offline"
+	operatorStatusNotSeen = "not_seen"
+)
+
+// operatorInfo is the public view of a contract operator
+type operatorInfo struct {
+	OperatorID        uint64         `json:"operator_id"`
+	PublicKey         string         `json:"public_key"`
+	Owner             string         `json:"owner,omitempty"`
+	Fee               string         `json:"fee,omitempty"`
+	DeclaredFee       string         `json:"declared_fee,omitempty"`
+	FeeHistory        []db.FeeChange `json:"fee_history"`
+	RegistrationBlock uint64         `json:"registration_block,omitempty"`
+}
+
+func newOperatorInfo(operator *db.Operator) *operatorInfo {
+	feeHistory := operator.FeeHistory
+	if feeHistory == nil {
+		feeHistory = []db.FeeChange{}
+	}
+	return &operatorInfo{
+		OperatorID:        operator.OperatorIDContract,
+		PublicKey:         operator.PublicKey,
+		Owner:             operator.Owner,
+		Fee:               operator.Fee,
+		DeclaredFee:       operator.DeclaredFee,
+		FeeHistory:        feeHistory,
+		RegistrationBlock: operator.RegistrationBlock,
+	}
+}
+
+// nodeWithOperator is a node response with the contract data of its operator
+type nodeWithOperator struct {
+	*db.NodeData
+	Operator *operatorInfo `json:"operator,omitempty"`
+}
+
+// withOperator joins the operator of the node, nodes without a known operator are returned as is
+func (api *Api) withOperator(nodeData *db.NodeData) (*nodeWithOperator, error) {
+	response := &nodeWithOperator{NodeData: nodeData}
+	operator, err := api.db.GetOperatorByOperatorId(nodeData.OperatorID)
+	if err == db.ErrNotFound {
+		return response, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	response.Operator = newOperatorInfo(operator)
+	return response, nil
+}
+
+// operatorResponse is a contract operator with the node it runs, if it was ever seen
+type operatorResponse struct {
+	*operatorInfo
+	Status string       `json:"status"`
+	Node   *db.NodeData `json:"node,omitempty"`
+}
+
+// publicOperators lists all contract operators joined with their public node data
+func (api *Api) publicOperators() ([]operatorResponse, error) {
+	operators, err := api.db.ListOperators()
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := api.publicNodes()
+	if err != nil {
+		return nil, err
+	}
+	return api.joinOperators(operators, nodes), nil
+}
+
+// joinOperators joins the operators with their node and status, sorted by operator id
+func (api *Api) joinOperators(operators []db.Operator, nodes []db.NodeData) []operatorResponse {
+	nodeByOperator := make(map[uint64]*db.NodeData, len(nodes))
+	for i := range nodes {
+		if nodes[i].OperatorIDContract != 0 {
+			nodeByOperator[nodes[i].OperatorIDContract] = &nodes[i]
+		}
+	}
+
+	now := time.Now()
+	result := make([]operatorResponse, 0, len(operators))
+	for i := range operators {
+		response := operatorResponse{
+			operatorInfo: newOperatorInfo(&operators[i]),
+			Status:       operatorStatusNotSeen,
+			Node:         nodeByOperator[operators[i].OperatorIDContract],
+		}
+		if response.Node != nil {
+			response.Status = operatorStatusOffline
+			if now.Sub(response.Node.UpdatedAt) <= api.config.OnlineWithin {
+				response.Status = operatorStatusOnline
+			}
+		}
+		result = append(result, response)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].OperatorID < result[j].OperatorID })
+	return result
+}
+
+// GetOperators lists every registered operator, optionally filtered by status
+func (api *Api) GetOperators(c *gin.Context) {
+	status := c.Query("status")
+	switch status {
+	case "", operatorStatusOnline, operatorStatusOffline, operatorStatusNotSeen:
+	default:
+		abort(c, http.StatusBadRequest, codeInvalidParameter, "unknown status")
+		return
+	}
+
+	operators, err := api.publicOperators()
+	if err != nil {
+		api.logger.Error("Error getting operators", zap.Error(err))
+		abortInternal(c)
+		return
+	}
+
+	counts := map[string]int{
+		operatorStatusOnline:  0,
+		operatorStatusOffline: 0,
+		operatorStatusNotSeen: 0,
+	}
+	filtered := make([]operatorResponse, 0, len(operators))
+	for _, operator := range operators {
+		counts[operator.Status]++
+		if status == "" || operator.Status == status {
+			filtered = append(filtered, operator)
+		}
+	}
+
+	online := 0.0
+	if len(operators) > 0 {
+		online = float64(counts[operatorStatusOnline]) / float64(len(operators))
+	}
+	api.respondList(c, "operators", filtered, gin.H{
+		"count":       len(filtered),
+		"total":       len(operators),
+		"statuses":    counts,
+		"online_rate": online,
+	})
+}
+
+// GetOperator returns a registered operator by its contract id
+func (api *Api) GetOperator(c *gin.Context) {
+	operatorID, err := utils.StringToUint64(c.Param("operatorid"))
+	if err != nil {
+		abort(c, http.StatusBadRequest, codeInvalidParameter, "invalid operator id")
+		return
+	}
+	operators, err := api.publicOperators()
+	if err != nil {
+		api.logger.Error("Error getting operators", zap.Error(err))
+		abortInternal(c)
+		return
+	}
+	for i := range operators {
+		if operators[i].OperatorID == operatorID {
+			api.respond(c, operators[i])
+			return
+		}
+	}
+	abortNotFound(c)
+}
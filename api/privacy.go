@@ -0,0 +1,193 @@
+package api
+
+import (
+	"math"
+
+	"github.com/stakestar/startracker/db"
+)
+
+// coordinate modes
+const (
+	CoordinateModeExact = "exact"
+	CoordinateModeGrid  = "grid"
+	CoordinateModeCity  = "city"
+)
+
+const kmPerDegree = 111.32
+
+// minNodesPerCity is the k-anonymity floor of the city mode, the centroid of a single node is its own location
+const minNodesPerCity = 2
+
+type PrivacyConfig struct {
+	HideIP         bool    `yaml:"HideIP" env:"API_PRIVACY_HIDE_IP" env-default:"true" env-description:"Hide node ip addresses from public responses"`
+	CoordinateMode string  `yaml:"CoordinateMode" env:"API_PRIVACY_COORDINATE_MODE" env-default:"exact" env-description:"Coordinates published in public responses (exact, grid, city)"`
+	GridSize       float64 `yaml:"GridSize" env:"API_PRIVACY_GRID_SIZE" env-default:"1" env-description:"Grid cell size in degrees used by the grid coordinate mode"`
+	// MinNodesPerLocation is the k of k-anonymity, locations shared by fewer nodes are generalized to the country, then hidden
+	MinNodesPerLocation int `yaml:"MinNodesPerLocation" env:"API_PRIVACY_MIN_NODES_PER_LOCATION" env-default:"0" env-description:"Minimum number of nodes sharing a published location"`
+}
+
+type locationKey struct {
+	countryCode string
+	city        string
+}
+
+type centroid struct {
+	latitude  float64
+	longitude float64
+	count     int
+}
+
+func (c *centroid) add(geoData *db.GeoData) {
+	c.latitude += geoData.Latitude
+	c.longitude += geoData.Longitude
+	c.count++
+}
+
+// apply moves the location to the centroid, the accuracy radius is widened by the distance the location moved
+func (c *centroid) apply(geoData *db.GeoData) {
+	latitude, longitude := geoData.Latitude, geoData.Longitude
+	geoData.Latitude = c.latitude / float64(c.count)
+	geoData.Longitude = c.longitude / float64(c.count)
+	moved := distanceKm(latitude, longitude, geoData.Latitude, geoData.Longitude)
+	geoData.AccuracyRadius = uint16(math.Min(math.Ceil(float64(geoData.AccuracyRadius)+moved), math.MaxUint16))
+}
+
+// applyPrivacy returns copies of the nodes with the public privacy rules applied,
+// it must be given the full node set since k-anonymity and centroids depend on all nodes
+func applyPrivacy(config *PrivacyConfig, nodes []db.NodeData) []db.NodeData {
+	result := make([]db.NodeData, len(nodes))
+	copy(result, nodes)
+
+	for i := range result {
+		applyNodePrivacy(config, &result[i])
+	}
+
+	k := config.MinNodesPerLocation
+	switch config.CoordinateMode {
+	case CoordinateModeCity:
+		snapToCentroids(result, func(geoData *db.GeoData) locationKey {
+			return locationKey{geoData.CountryCode, geoData.City}
+		})
+		if k < minNodesPerCity {
+			k = minNodesPerCity
+		}
+	}
+
+	if k > 1 {
+		kAnonymize(result, k)
+	}
+
+	return result
+}
+
+// nodePrivacyOnly reports whether the privacy rules can be applied to every node on its own, without the full node set
+func nodePrivacyOnly(config *PrivacyConfig) bool {
+	return config.CoordinateMode != CoordinateModeCity && config.MinNodesPerLocation <= 1
+}
+
+// exactLocations reports whether the public locations are the stored ones
+func exactLocations(config *PrivacyConfig) bool {
+	mode := config.CoordinateMode
+	return (mode == "" || mode == CoordinateModeExact) && config.MinNodesPerLocation <= 1
+}
+
+// applyNodePrivacy applies the rules that depend on the node only, the ip, the claim and the grid snapping
+func applyNodePrivacy(config *PrivacyConfig, node *db.NodeData) {
+	if config.HideIP {
+		node.IPAddress = ""
+	}
+	// claims are signed over their exact coordinates, they cannot be generalized without breaking the signature
+	if !exactLocations(config) {
+		node.ClaimedGeo = nil
+	}
+	if config.CoordinateMode == CoordinateModeGrid {
+		snapToGrid(&node.GeoData, config.GridSize)
+	}
+}
+
+// snapToGrid moves the coordinates to the center of their grid cell
+func snapToGrid(geoData *db.GeoData, size float64) {
+	if size <= 0 || !hasLocation(geoData) {
+		return
+	}
+	geoData.Latitude = (math.Floor(geoData.Latitude/size) + 0.5) * size
+	geoData.Longitude = (math.Floor(geoData.Longitude/size) + 0.5) * size
+	// half of the cell diagonal
+	radius := uint16(math.Min(math.Sqrt2*size*kmPerDegree/2, math.MaxUint16))
+	if radius > geoData.AccuracyRadius {
+		geoData.AccuracyRadius = radius
+	}
+}
+
+// snapToCentroids moves the coordinates of every node to the centroid of the nodes sharing its key
+func snapToCentroids(nodes []db.NodeData, keyOf func(geoData *db.GeoData) locationKey) {
+	centroids := make(map[locationKey]*centroid)
+	for i := range nodes {
+		geoData := &nodes[i].GeoData
+		if !hasLocation(geoData) {
+			continue
+		}
+		key := keyOf(geoData)
+		if centroids[key] == nil {
+			centroids[key] = &centroid{}
+		}
+		centroids[key].add(geoData)
+	}
+	for i := range nodes {
+		geoData := &nodes[i].GeoData
+		if !hasLocation(geoData) {
+			continue
+		}
+		centroids[keyOf(geoData)].apply(geoData)
+	}
+}
+
+// kAnonymize generalizes locations shared by fewer than k nodes to their country centroid,
+// and hides the location of nodes whose country is still shared by fewer than k nodes
+func kAnonymize(nodes []db.NodeData, k int) {
+	cities := make(map[locationKey]int)
+	for i := range nodes {
+		geoData := &nodes[i].GeoData
+		if hasLocation(geoData) {
+			cities[locationKey{geoData.CountryCode, geoData.City}]++
+		}
+	}
+
+	var generalized []int
+	for i := range nodes {
+		geoData := &nodes[i].GeoData
+		if hasLocation(geoData) && cities[locationKey{geoData.CountryCode, geoData.City}] < k {
+			generalized = append(generalized, i)
+		}
+	}
+	if len(generalized) == 0 {
+		return
+	}
+
+	countries := make(map[string]*centroid)
+	for i := range nodes {
+		geoData := &nodes[i].GeoData
+		if !hasLocation(geoData) {
+			continue
+		}
+		if countries[geoData.CountryCode] == nil {
+			countries[geoData.CountryCode] = &centroid{}
+		}
+		countries[geoData.CountryCode].add(geoData)
+	}
+
+	for _, i := range generalized {
+		geoData := &nodes[i].GeoData
+		country := countries[geoData.CountryCode]
+		if country.count < k {
+			nodes[i].GeoData = db.GeoData{}
+			continue
+		}
+		country.apply(geoData)
+		geoData.City = ""
+	}
+}
+
+func hasLocation(geoData *db.GeoData) bool {
+	return geoData.CountryCode != "" || geoData.Latitude != 0 || geoData.Longitude != 0
+}
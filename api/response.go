@@ -0,0 +1,159 @@
+package api
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/graphql-go/graphql/gqlerrors"
+)
+
+const (
+	v1Prefix = "/api/v1"
+
+	defaultPageLimit = 100
+	maxPageLimit     = 1000
+)
+
+// error codes of v1 error responses
+const (
+	codeInvalidParameter = "invalid_parameter"
+	codeNotFound         = "not_found"
+	codeUnauthorized     = "unauthorized"
+	codeInvalidSignature = "invalid_signature"
+	codeAdminRequired    = "admin_required"
+	codeClaimOutdated    = "claim_outdated"
+	codeRateLimited      = "rate_limited"
+	codeInternal         = "internal_error"
+)
+
+// errorObject is the error of a v1 response
+type errorObject struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type pagination struct {
+	Offset int `json:"offset"`
+	Limit  int `json:"limit"`
+	Total  int `json:"total"`
+}
+
+// envelope is the body of every successful v1 response, pagination is set for lists
+type envelope struct {
+	Data       interface{} `json:"data"`
+	Pagination *pagination `json:"pagination,omitempty"`
+	Metadata   gin.H       `json:"metadata"`
+}
+
+// isV1 reports whether the request uses the v1 routes, the other /api routes are deprecated aliases
+// answering with the unversioned response shapes
+func isV1(c *gin.Context) bool {
+	return strings.HasPrefix(c.Request.URL.Path, v1Prefix+"/")
+}
+
+// abort responds with an error, v1 and GraphQL requests get the machine readable code
+func abort(c *gin.Context, status int, code, message string) {
+	// validators set before the handler failed do not apply to the error
+	c.Writer.Header().Del("ETag")
+	c.Writer.Header().Del("Last-Modified")
+	if isGraphQL(c) {
+		c.AbortWithStatusJSON(status, graphqlErrors(code, []gqlerrors.FormattedError{{Message: message}}))
+		return
+	}
+	if isV1(c) {
+		c.AbortWithStatusJSON(status, gin.H{"error": errorObject{Code: code, Message: message}})
+		return
+	}
+	c.AbortWithStatusJSON(status, gin.H{"error": message})
+}
+
+func abortInternal(c *gin.Context) {
+	abort(c, http.StatusInternalServerError, codeInternal, "internal server error")
+}
+
+func abortNotFound(c *gin.Context) {
+	abort(c, http.StatusNotFound, codeNotFound, "not found")
+}
+
+func (api *Api) metadata(extra gin.H) gin.H {
+	metadata := gin.H{
+		"network":      api.config.Network,
+		"generated_at": time.Now().UTC(),
+	}
+	for k, v := range extra {
+		metadata[k] = v
+	}
+	return metadata
+}
+
+// respond responds with a single object
+func (api *Api) respond(c *gin.Context, data interface{}) {
+	if !isV1(c) {
+		c.JSON(http.StatusOK, data)
+		return
+	}
+	c.JSON(http.StatusOK, envelope{Data: data, Metadata: api.metadata(nil)})
+}
+
+// respondList responds with a list, unversioned responses have the items under key and are not paginated
+func (api *Api) respondList(c *gin.Context, key string, items interface{}, metadata gin.H) {
+	if !isV1(c) {
+		c.JSON(http.StatusOK, gin.H{key: items, "metadata": metadata})
+		return
+	}
+
+	offset, limit, ok := pageParams(c)
+	if !ok {
+		return
+	}
+
+	list := reflect.ValueOf(items)
+	if list.IsNil() {
+		list = reflect.MakeSlice(list.Type(), 0, 0)
+	}
+	total := list.Len()
+	start, end := page(offset, limit, total)
+	c.JSON(http.StatusOK, envelope{
+		Data:       list.Slice(start, end).Interface(),
+		Pagination: &pagination{Offset: offset, Limit: limit, Total: total},
+		Metadata:   api.metadata(metadata),
+	})
+}
+
+// pageParams parses the offset and limit query parameters of a v1 list
+func pageParams(c *gin.Context) (offset, limit int, ok bool) {
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		abort(c, http.StatusBadRequest, codeInvalidParameter, "offset must be a non negative number")
+		return 0, 0, false
+	}
+	limit, err = strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultPageLimit)))
+	if err != nil || limit < 1 || limit > maxPageLimit {
+		abort(c, http.StatusBadRequest, codeInvalidParameter, "limit must be between 1 and "+strconv.Itoa(maxPageLimit))
+		return 0, 0, false
+	}
+	return offset, limit, true
+}
+
+// page returns the bounds of the requested page of a list of total items
+func page(offset, limit, total int) (start, end int) {
+	start, end = offset, offset+limit
+	if start > total {
+		start = total
+	}
+	if end > total {
+		end = total
+	}
+	return start, end
+}
+
+// deprecated marks the unversioned routes with their v1 successor
+func deprecated(c *gin.Context) {
+	c.Header("Deprecation", "true")
+	c.Header("Link", "<"+v1Prefix+strings.TrimPrefix(c.Request.URL.Path, "/api")+`>; rel="successor-version"`)
+	c.Next()
+}
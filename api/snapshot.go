@@ -0,0 +1,56 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// snapshot formats
+const (
+	snapshotFormatJSONL = "jsonl"
+	snapshotFormatBolt  = "bolt"
+)
+
+// boltSnapshotter is implemented by the bolt backed stores
+type boltSnapshotter interface {
+	WriteSnapshot(w io.Writer) (int64, error)
+}
+
+// AdminGetSnapshot streams a consistent copy of the database while the tracker keeps running,
+// jsonl exports can be loaded with db import, bolt files can be served by api mode replicas
+func (api *Api) AdminGetSnapshot(c *gin.Context) {
+	format := c.DefaultQuery("format", snapshotFormatJSONL)
+	filename := "startracker-" + time.Now().UTC().Format("20060102T150405Z")
+
+	var err error
+	switch format {
+	case snapshotFormatJSONL:
+		c.Header("Content-Type", "application/x-ndjson")
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename+".jsonl"))
+		c.Status(http.StatusOK)
+		err = api.db.Export(c.Writer)
+	case snapshotFormatBolt:
+		snapshotter, ok := api.db.(boltSnapshotter)
+		if !ok {
+			abort(c, http.StatusBadRequest, codeInvalidParameter, "bolt snapshots require the bolt database driver")
+			return
+		}
+		c.Header("Content-Type", "application/octet-stream")
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename+".db"))
+		c.Status(http.StatusOK)
+		_, err = snapshotter.WriteSnapshot(c.Writer)
+	default:
+		abort(c, http.StatusBadRequest, codeInvalidParameter, "unknown snapshot format")
+		return
+	}
+	if err != nil {
+		// the status is already sent, the truncated body is the only signal left to the client
+		api.logger.Error("could not write snapshot", zap.String("format", format), zap.Error(err))
+		_ = c.Error(err)
+	}
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stakestar/startracker/db"
+	"go.uber.org/zap"
+)
+
+// concentration describes how the nodes are spread over the groups of a dimension
+type concentration struct {
+	Groups int `json:"groups"`
+	// Nakamoto is the smallest number of groups running more than a third of the nodes
+	Nakamoto int `json:"nakamoto"`
+	// HHI is the Herfindahl-Hirschman index, the sum of the squared shares between 0 and 1
+	HHI      float64 `json:"hhi"`
+	TopShare float64 `json:"top_share"`
+}
+
+func newConcentration(counts map[string]int) concentration {
+	values := make([]int, 0, len(counts))
+	total := 0
+	for _, count := range counts {
+		if count == 0 {
+			continue
+		}
+		values = append(values, count)
+		total += count
+	}
+	result := concentration{Groups: len(values)}
+	if total == 0 {
+		return result
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(values)))
+
+	result.TopShare = float64(values[0]) / float64(total)
+	sum := 0
+	for _, count := range values {
+		share := float64(count) / float64(total)
+		result.HHI += share * share
+		if sum*3 <= total {
+			sum += count
+			result.Nakamoto++
+		}
+	}
+	return result
+}
+
+// stats is the decentralization of the nodes with a known operator
+type stats struct {
+	Nodes     int           `json:"nodes"`
+	Operators int           `json:"operators"`
+	Entities  concentration `json:"entities"`
+	Countries concentration `json:"countries"`
+	ASNs      concentration `json:"asns"`
+}
+
+// newStats computes the decentralization of the nodes by entity, country and ASN
+func newStats(entities []entity, nodes []db.NodeData) *stats {
+	byEntity := make(map[string]int)
+	operators := 0
+	for _, e := range entities {
+		byEntity[e.ID] = e.Nodes
+		operators += len(e.Operators)
+	}
+	byCountry := make(map[string]int)
+	byASN := make(map[string]int)
+	count := 0
+	for _, node := range nodes {
+		if node.OperatorIDContract == 0 {
+			continue
+		}
+		count++
+		if node.GeoData.CountryCode != "" {
+			byCountry[node.GeoData.CountryCode]++
+		}
+		if node.GeoData.ASN != 0 {
+			byASN[strconv.FormatUint(uint64(node.GeoData.ASN), 10)]++
+		}
+	}
+
+	return &stats{
+		Nodes:     count,
+		Operators: operators,
+		Entities:  newConcentration(byEntity),
+		Countries: newConcentration(byCountry),
+		ASNs:      newConcentration(byASN),
+	}
+}
+
+// GetStats returns the decentralization of the nodes by entity, country and ASN
+func (api *Api) GetStats(c *gin.Context) {
+	entities, err := api.entities()
+	if err != nil {
+		api.logger.Error("Error building entities", zap.Error(err))
+		abortInternal(c)
+		return
+	}
+	nodes, err := api.publicNodes()
+	if err != nil {
+		api.logger.Error("Error getting nodes", zap.Error(err))
+		abortInternal(c)
+		return
+	}
+	api.respond(c, newStats(entities, nodes))
+}
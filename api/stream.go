@@ -0,0 +1,159 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stakestar/startracker/db"
+	"github.com/stakestar/startracker/versions"
+	"go.uber.org/zap"
+)
+
+const (
+	// streamBufferSize is the size of the writes of a streamed body
+	streamBufferSize = 32 * 1024
+	// streamPageSize is the number of nodes read from the store at once for a streamed body
+	streamPageSize = 500
+)
+
+// latestVersionCache keeps the latest version of a data revision, streamed listings need it before reading the nodes
+type latestVersionCache struct {
+	mu       sync.Mutex
+	revision uint64
+	loaded   bool
+	latest   *versions.Version
+}
+
+func (api *Api) latestVersion() (*versions.Version, error) {
+	revision, err := api.db.Revision()
+	if err != nil {
+		return nil, err
+	}
+
+	api.latest.mu.Lock()
+	defer api.latest.mu.Unlock()
+	if api.latest.loaded && api.latest.revision == revision.Number {
+		return api.latest.latest, nil
+	}
+	latest, err := api.versions.LatestStored()
+	if err != nil {
+		return nil, err
+	}
+	api.latest.revision = revision.Number
+	api.latest.loaded = true
+	api.latest.latest = latest
+	return latest, nil
+}
+
+// streamNodes responds like respondNodes with the nodes read from the store in pages, they are encoded one by one
+// instead of being loaded and marshaled at once. Public nodes can only be streamed when the privacy rules
+// apply to every node on its own, see nodePrivacyOnly
+func (api *Api) streamNodes(c *gin.Context, onlyWithOperatorId, public bool) {
+	offset, limit := 0, -1
+	if isV1(c) {
+		var ok bool
+		offset, limit, ok = pageParams(c)
+		if !ok {
+			return
+		}
+	}
+
+	latest, err := api.latestVersion()
+	if err != nil {
+		api.logger.Error("Error getting latest version", zap.Error(err))
+		abortInternal(c)
+		return
+	}
+
+	c.Header("Content-Type", "application/json; charset=utf-8")
+	c.Status(http.StatusOK)
+	w := bufio.NewWriterSize(c.Writer, streamBufferSize)
+	encoder := json.NewEncoder(w)
+
+	key := "nodes"
+	if isV1(c) {
+		key = "data"
+	}
+	_, err = w.WriteString(`{"` + key + `":[`)
+	total := 0
+	after := ""
+	for err == nil {
+		// the page is read before it is written, a slow client must not hold a store transaction
+		var nodes []db.NodeData
+		nodes, err = api.db.ListNodeDataPage(onlyWithOperatorId, after, streamPageSize)
+		if err != nil || len(nodes) == 0 {
+			break
+		}
+		after = nodes[len(nodes)-1].OperatorID
+		for i := range nodes {
+			index := total
+			total++
+			if index < offset || limit >= 0 && index >= offset+limit {
+				continue
+			}
+			node := &nodes[i]
+			node.Outdated = versions.IsOutdated(node.NodeVersion, latest)
+			if public {
+				applyNodePrivacy(&api.config.Privacy, node)
+			}
+			if err = api.verifyClaim(node); err != nil {
+				break
+			}
+			if index > offset {
+				if err = w.WriteByte(','); err != nil {
+					break
+				}
+			}
+			if err = encoder.Encode(node); err != nil {
+				break
+			}
+		}
+	}
+	if err == nil {
+		err = api.writeStreamTail(w, isV1(c), offset, limit, total)
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		return
+	}
+
+	api.logger.Error("Error streaming nodes", zap.Error(err))
+	if !c.Writer.Written() {
+		// the failure happened before the first buffer was flushed
+		abortInternal(c)
+		return
+	}
+	// the status is already sent, the truncated body is the only signal left to the client
+	_ = c.Error(err)
+	c.Abort()
+}
+
+// writeStreamTail closes the streamed list with the fields known once all nodes are read
+func (api *Api) writeStreamTail(w *bufio.Writer, v1 bool, offset, limit, total int) error {
+	metadata := gin.H{"count": total}
+	var tail interface{} = struct {
+		Metadata gin.H `json:"metadata"`
+	}{metadata}
+	if v1 {
+		tail = struct {
+			Pagination pagination `json:"pagination"`
+			Metadata   gin.H      `json:"metadata"`
+		}{pagination{Offset: offset, Limit: limit, Total: total}, api.metadata(metadata)}
+	}
+	fields, err := json.Marshal(tail)
+	if err != nil {
+		return err
+	}
+	// the fields are appended to the object opened with the list
+	fields[0] = ','
+	if _, err := w.WriteString("]"); err != nil {
+		return err
+	}
+	_, err = w.Write(fields)
+	return err
+}
@@ -0,0 +1,135 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stakestar/startracker/db"
+	"github.com/stakestar/startracker/versions"
+	"go.uber.org/zap"
+)
+
+const benchmarkNodes = 50000
+
+// discardWriter is a response writer dropping the body, so that only the memory of the handler is measured
+type discardWriter struct {
+	header http.Header
+}
+
+func (w *discardWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *discardWriter) Write(data []byte) (int, error) {
+	return len(data), nil
+}
+
+func (w *discardWriter) WriteHeader(int) {}
+
+// newBenchmarkApi returns an api over a bolt store of synthetic nodes, imported in one transaction
+func newBenchmarkApi(b *testing.B, nodes int) *Api {
+	store, err := db.NewBoltDB(filepath.Join(b.TempDir(), "bench.db"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { _ = store.Close() })
+
+	var export bytes.Buffer
+	encoder := json.NewEncoder(&export)
+	header, _ := json.Marshal(map[string]interface{}{"version": 1, "exported_at": time.Now().UTC()})
+	if err := encoder.Encode(db.ExportRecord{Type: db.RecordHeader, Data: header}); err != nil {
+		b.Fatal(err)
+	}
+	for i := 0; i < nodes; i++ {
+		data, err := json.Marshal(db.NodeData{
+			UpdatedAt:          time.Now(),
+			IPAddress:          fmt.Sprintf("10.%d.%d.%d", i>>16&0xff, i>>8&0xff, i&0xff),
+			NodeVersion:        fmt.Sprintf("v1.%d.0", i%5),
+			OperatorIDContract: uint64(i + 1),
+			GeoData: db.GeoData{
+				CountryCode: "DE",
+				CountryName: "Germany",
+				City:        fmt.Sprintf("City %d", i%100),
+				Latitude:    float64(i%180) - 90 + 0.5,
+				Longitude:   float64(i%360) - 180 + 0.5,
+			},
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+		record := db.ExportRecord{Type: db.RecordNode, Key: fmt.Sprintf("operator-%06d", i), Data: data}
+		if err := encoder.Encode(record); err != nil {
+			b.Fatal(err)
+		}
+	}
+	if _, err := store.Import(&export); err != nil {
+		b.Fatal(err)
+	}
+
+	logger := zap.NewNop()
+	tracker, err := versions.NewTracker(&versions.Config{}, store, logger)
+	if err != nil {
+		b.Fatal(err)
+	}
+	config := &Config{Privacy: PrivacyConfig{HideIP: true, CoordinateMode: CoordinateModeExact}}
+	return New(logger, store, config, tracker)
+}
+
+func benchmarkContext(path string) *gin.Context {
+	c, _ := gin.CreateTestContext(&discardWriter{header: make(http.Header)})
+	c.Request = httptest.NewRequest(http.MethodGet, path, nil)
+	return c
+}
+
+// BenchmarkNodesAll compares the unversioned node listing built in memory and buffered, as done for the privacy
+// rules needing the whole node set, with the listing streamed from the store in pages
+func BenchmarkNodesAll(b *testing.B) {
+	gin.SetMode(gin.ReleaseMode)
+	api := newBenchmarkApi(b, benchmarkNodes)
+
+	b.Run("buffered", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			c := benchmarkContext("/api/nodes/all")
+			writer := &bufferedWriter{ResponseWriter: c.Writer, status: http.StatusOK}
+			c.Writer = writer
+			nodes, err := api.publicNodes()
+			if err != nil {
+				b.Fatal(err)
+			}
+			api.respondNodes(c, nodes)
+			c.Writer = writer.ResponseWriter
+			c.Data(writer.status, writer.Header().Get("Content-Type"), writer.body.Bytes())
+		}
+	})
+
+	b.Run("streamed", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			api.GetAllNodes(benchmarkContext("/api/nodes/all"))
+		}
+	})
+
+	for _, encoding := range []string{encodingGzip, encodingBrotli} {
+		encoding := encoding
+		b.Run("streamed-"+encoding, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				c := benchmarkContext("/api/nodes/all")
+				writer := &compressWriter{ResponseWriter: c.Writer, encoding: encoding, status: http.StatusOK}
+				c.Writer = writer
+				api.GetAllNodes(c)
+				if err := writer.finish(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stakestar/startracker/versions"
+	"go.uber.org/zap"
+)
+
+type versionCount struct {
+	Version  string  `json:"version"`
+	Count    int     `json:"count"`
+	Share    float64 `json:"share"`
+	Outdated bool    `json:"outdated"`
+}
+
+type versionAdoption struct {
+	Date        string         `json:"date"`
+	Total       int            `json:"total"`
+	LatestShare float64        `json:"latest_share"`
+	Versions    []versionCount `json:"versions"`
+}
+
+// versionsReport is the latest version with the current and daily version distributions
+type versionsReport struct {
+	Latest  string            `json:"latest"`
+	Current versionAdoption   `json:"current"`
+	History []versionAdoption `json:"history"`
+}
+
+// versionsReport builds the current version distribution and the daily adoption history
+func (api *Api) versionsReport() (*versionsReport, error) {
+	nodes, err := api.db.ListNodeData(false)
+	if err != nil {
+		return nil, err
+	}
+	histograms, err := api.db.ListVersionHistograms()
+	if err != nil {
+		return nil, err
+	}
+
+	latest := api.versions.Latest(nodes)
+	latestVersion := ""
+	if latest != nil {
+		latestVersion = latest.String()
+	}
+
+	// the current distribution counts the same active nodes as the daily histograms
+	current := api.versions.Counts(nodes, time.Now())
+
+	history := make([]versionAdoption, 0, len(histograms))
+	for _, histogram := range histograms {
+		history = append(history, adoption(histogram.Date, histogram.Counts, latest))
+	}
+
+	return &versionsReport{
+		Latest:  latestVersion,
+		Current: adoption("", current, latest),
+		History: history,
+	}, nil
+}
+
+// GetVersions returns the current version distribution and the daily adoption history
+func (api *Api) GetVersions(c *gin.Context) {
+	report, err := api.versionsReport()
+	if err != nil {
+		api.logger.Error("Error building versions report", zap.Error(err))
+		abortInternal(c)
+		return
+	}
+	api.respond(c, report)
+}
+
+func adoption(date string, counts map[string]int, latest *versions.Version) versionAdoption {
+	total := 0
+	for _, count := range counts {
+		total += count
+	}
+	result := versionAdoption{
+		Date:     date,
+		Total:    total,
+		Versions: make([]versionCount, 0, len(counts)),
+	}
+	for _, version := range versions.Sorted(counts) {
+		vc := versionCount{
+			Version:  version,
+			Count:    counts[version],
+			Outdated: versions.IsOutdated(version, latest),
+		}
+		if total > 0 {
+			vc.Share = float64(vc.Count) / float64(total)
+		}
+		if latest != nil && version == latest.String() {
+			result.LatestShare = vc.Share
+		}
+		result.Versions = append(result.Versions, vc)
+	}
+	return result
+}
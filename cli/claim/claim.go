@@ -0,0 +1,64 @@
+package claim
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/stakestar/startracker/db"
+	"github.com/stakestar/startracker/keys"
+)
+
+var (
+	privateKeyPath string
+	claim          db.LocationClaim
+)
+
+// ClaimCmd signs a location claim with an operator private key,
+// the output is the body to POST to /api/nodes/operatorid/{operatorid}/claim
+var ClaimCmd = &cobra.Command{
+	Use:   "claim-location",
+	Short: "Signs a location claim with an operator private key",
+	Run: func(cmd *cobra.Command, args []string) {
+		raw, err := os.ReadFile(privateKeyPath)
+		if err != nil {
+			log.Fatal("Error reading private key file: ", err)
+		}
+		privateKey, err := keys.ParsePrivateKey(string(raw))
+		if err != nil {
+			log.Fatal("Error parsing private key: ", err)
+		}
+
+		claim.CountryCode = strings.ToUpper(claim.CountryCode)
+		claim.Timestamp = time.Now().Unix()
+		claim.Signature, err = keys.Sign(privateKey, claim.Message())
+		if err != nil {
+			log.Fatal("Error signing claim: ", err)
+		}
+
+		body, err := json.MarshalIndent(claim, "", "  ")
+		if err != nil {
+			log.Fatal("Error encoding claim: ", err)
+		}
+		fmt.Println(string(body))
+	},
+}
+
+func init() {
+	ClaimCmd.Flags().StringVar(&privateKeyPath, "private-key-path", "", "Path to the operator private key file")
+	ClaimCmd.Flags().Uint64Var(&claim.OperatorIDContract, "operator-id", 0, "Operator ID")
+	ClaimCmd.Flags().StringVar(&claim.CountryCode, "country-code", "", "ISO country code of the node location")
+	ClaimCmd.Flags().StringVar(&claim.City, "city", "", "City of the node location")
+	ClaimCmd.Flags().Float64Var(&claim.Latitude, "latitude", 0, "Latitude of the node location")
+	ClaimCmd.Flags().Float64Var(&claim.Longitude, "longitude", 0, "Longitude of the node location")
+	_ = ClaimCmd.MarkFlagRequired("private-key-path")
+	_ = ClaimCmd.MarkFlagRequired("operator-id")
+	_ = ClaimCmd.MarkFlagRequired("country-code")
+	_ = ClaimCmd.MarkFlagRequired("latitude")
+	_ = ClaimCmd.MarkFlagRequired("longitude")
+}
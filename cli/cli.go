@@ -4,6 +4,10 @@ import (
 	"log"
 
 	"github.com/spf13/cobra"
+	"github.com/stakestar/startracker/cli/claim"
+	"github.com/stakestar/startracker/cli/database"
+	"github.com/stakestar/startracker/cli/events"
+	"github.com/stakestar/startracker/cli/geo"
 	"github.com/stakestar/startracker/cli/node"
 	"go.uber.org/zap"
 )
@@ -29,4 +33,8 @@ func Execute(appName, version string) {
 
 func init() {
 	RootCmd.AddCommand(node.StartNodeCmd)
+	RootCmd.AddCommand(geo.GeoCmd)
+	RootCmd.AddCommand(claim.ClaimCmd)
+	RootCmd.AddCommand(database.DbCmd)
+	RootCmd.AddCommand(events.EthCmd)
 }
@@ -0,0 +1,39 @@
+package config
+
+import (
+	"errors"
+	"time"
+
+	"github.com/ilyakaznacheev/cleanenv"
+	"github.com/stakestar/startracker/alerts"
+	"github.com/stakestar/startracker/api"
+	"github.com/stakestar/startracker/eth"
+	"github.com/stakestar/startracker/geodata"
+	"github.com/stakestar/startracker/p2p"
+	"github.com/stakestar/startracker/versions"
+)
+
+// Config is the configuration shared by all startracker commands
+type Config struct {
+	P2pNetworkConfig         p2p.Config            `yaml:"p2p"`
+	DbPath                   string                `yaml:"dbPath" env:"DB_PATH" env-description:"Path to database file" env-default:"data/nodes.db"`
+	DbDriver                 string                `yaml:"dbDriver" env:"DB_DRIVER" env-description:"Database driver (bolt, sqlite, postgres)" env-default:"bolt"`
+	DbDSN                    string                `yaml:"dbDSN" env:"DB_DSN" env-description:"Data source name of the sqlite or postgres database"`
+	DbSnapshotReloadInterval time.Duration         `yaml:"dbSnapshotReloadInterval" env:"DB_SNAPSHOT_RELOAD_INTERVAL" env-description:"Interval between bolt snapshot change checks in api mode" env-default:"1m"`
+	ShutdownTimeout          time.Duration         `yaml:"shutdownTimeout" env:"SHUTDOWN_TIMEOUT" env-description:"Time given to every component to stop on shutdown" env-default:"5s"`
+	GeoDataDbPath            string                `yaml:"geoDataDbPath" env:"GEO_DATA_DB_PATH" env-description:"Path to geo data database file" env-default:"GeoLite2-City.mmdb"`
+	GeoDataUpdater           geodata.UpdaterConfig `yaml:"geoDataUpdater"`
+	GeoProviders             geodata.Config        `yaml:"geoProviders"`
+	EventsConfig             eth.Config            `yaml:"eventsConfig"`
+	ApiConfig                api.Config            `yaml:"api"`
+	Alerts                   alerts.Config         `yaml:"alerts"`
+	Versions                 versions.Config       `yaml:"versions"`
+}
+
+// Load reads the config file at path, environment variables override file values
+func Load(path string, cfg *Config) error {
+	if path == "" {
+		return errors.New("config path is required")
+	}
+	return cleanenv.ReadConfig(path, cfg)
+}
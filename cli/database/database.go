@@ -0,0 +1,107 @@
+package database
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+
+	"github.com/stakestar/startracker/cli/args"
+	"github.com/stakestar/startracker/cli/config"
+	"github.com/stakestar/startracker/db"
+	"github.com/stakestar/startracker/logger"
+)
+
+var cfg config.Config
+
+var globalArgs args.GlobalArgs
+
+var (
+	outputPath string
+	inputPath  string
+)
+
+// DbCmd groups the database maintenance commands
+var DbCmd = &cobra.Command{
+	Use:   "db",
+	Short: "Manage the tracker database",
+}
+
+// ExportCmd writes the database as json lines, the database must not be held by a running tracker when using bolt
+var ExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Exports the database as json lines",
+	Run: func(cmd *cobra.Command, args []string) {
+		logger, store := open()
+		defer logger.Sync()
+		defer store.Close()
+
+		file, err := os.Create(outputPath)
+		if err != nil {
+			logger.Fatal("Error creating output file", zap.Error(err))
+		}
+		defer file.Close()
+
+		if err := store.Export(file); err != nil {
+			logger.Fatal("Error exporting database", zap.Error(err))
+		}
+		logger.Info("exported database", zap.String("path", outputPath))
+	},
+}
+
+// ImportCmd upserts the records of an export into the database
+var ImportCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Imports a json lines export into the database",
+	Run: func(cmd *cobra.Command, args []string) {
+		logger, store := open()
+		defer logger.Sync()
+		defer store.Close()
+
+		file, err := os.Open(inputPath)
+		if err != nil {
+			logger.Fatal("Error opening input file", zap.Error(err))
+		}
+		defer file.Close()
+
+		stats, err := store.Import(file)
+		if err != nil {
+			logger.Fatal("Error importing database", zap.Error(err))
+		}
+		logger.Info("imported database",
+			zap.Int("operators", stats[db.RecordOperator]),
+			zap.Int("nodes", stats[db.RecordNode]),
+			zap.Int("locationClaims", stats[db.RecordLocationClaim]),
+			zap.Int("versionHistograms", stats[db.RecordVersionHistogram]),
+		)
+	},
+}
+
+func open() (*zap.Logger, db.Store) {
+	if err := config.Load(globalArgs.ConfigPath, &cfg); err != nil {
+		log.Fatal("Error reading config file", err)
+	}
+
+	logger, err := logger.Create(globalArgs.LogLevel)
+	if err != nil {
+		fmt.Println("Error initializing logger")
+	}
+
+	store, err := db.Open(cfg.DbDriver, cfg.DbPath, cfg.DbDSN)
+	if err != nil {
+		logger.Fatal("Error connecting to database", zap.Error(err))
+	}
+	return logger, store
+}
+
+func init() {
+	args.ProcessArgs(&globalArgs, DbCmd)
+	ExportCmd.Flags().StringVarP(&outputPath, "output", "o", "", "Export file path")
+	_ = ExportCmd.MarkFlagRequired("output")
+	ImportCmd.Flags().StringVarP(&inputPath, "input", "i", "", "Export file path")
+	_ = ImportCmd.MarkFlagRequired("input")
+	DbCmd.AddCommand(ExportCmd)
+	DbCmd.AddCommand(ImportCmd)
+}
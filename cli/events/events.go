@@ -0,0 +1,93 @@
+package events
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+
+	"github.com/stakestar/startracker/cli/args"
+	"github.com/stakestar/startracker/cli/config"
+	"github.com/stakestar/startracker/db"
+	"github.com/stakestar/startracker/eth"
+	"github.com/stakestar/startracker/logger"
+)
+
+var cfg config.Config
+
+var globalArgs args.GlobalArgs
+
+var (
+	fromBlock uint64
+	toBlock   uint64
+	dryRun    bool
+)
+
+// EthCmd groups the contract event indexer commands
+var EthCmd = &cobra.Command{
+	Use:   "eth",
+	Short: "Manage the contract event indexer",
+}
+
+// ResyncCmd fetches the contract events of a block range again and upserts the operators
+var ResyncCmd = &cobra.Command{
+	Use:   "resync",
+	Short: "Re-fetches the contract events of a block range and upserts the operators",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := config.Load(globalArgs.ConfigPath, &cfg); err != nil {
+			log.Fatal("Error reading config file", err)
+		}
+
+		logger, err := logger.Create(globalArgs.LogLevel)
+		if err != nil {
+			fmt.Println("Error initializing logger")
+		}
+		defer logger.Sync()
+
+		store, err := db.Open(cfg.DbDriver, cfg.DbPath, cfg.DbDSN)
+		if err != nil {
+			logger.Fatal("Error connecting to database", zap.Error(err))
+		}
+		defer store.Close()
+
+		events, err := eth.NewEthEvents(cmd.Context(), &cfg.EventsConfig, store, logger)
+		if err != nil {
+			logger.Fatal("Error connecting to eth events", zap.Error(err))
+		}
+		if err := events.Start(); err != nil {
+			logger.Fatal("Error starting eth events", zap.Error(err))
+		}
+		defer events.Close()
+
+		result, err := events.Resync(fromBlock, toBlock, dryRun)
+		if result != nil {
+			logger.Info("finished resync",
+				zap.Uint64("from", result.FromBlock),
+				zap.Uint64("to", result.ToBlock),
+				zap.Int("events", result.Events),
+				zap.Int("applied", result.Applied),
+				zap.Int("failed", result.Failed),
+				zap.Bool("dryRun", dryRun),
+			)
+		}
+		if err != nil {
+			logger.Error("Error resyncing events", zap.Error(err))
+			// os.Exit skips the deferred calls
+			_ = events.Close()
+			_ = store.Close()
+			_ = logger.Sync()
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	args.ProcessArgs(&globalArgs, EthCmd)
+	ResyncCmd.Flags().Uint64Var(&fromBlock, "from-block", 0, "First block to resync")
+	_ = ResyncCmd.MarkFlagRequired("from-block")
+	ResyncCmd.Flags().Uint64Var(&toBlock, "to-block", 0, "Last block to resync, the current block if not set")
+	ResyncCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Log the events that would be applied without changing the database")
+	EthCmd.AddCommand(ResyncCmd)
+}
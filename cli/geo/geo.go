@@ -0,0 +1,98 @@
+package geo
+
+import (
+	"fmt"
+	"log"
+	"net"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+
+	"github.com/stakestar/startracker/cli/args"
+	"github.com/stakestar/startracker/cli/config"
+	"github.com/stakestar/startracker/db"
+	"github.com/stakestar/startracker/geodata"
+	"github.com/stakestar/startracker/logger"
+)
+
+var cfg config.Config
+
+var globalArgs args.GlobalArgs
+
+var download bool
+
+// GeoCmd groups the geo data maintenance commands
+var GeoCmd = &cobra.Command{
+	Use:   "geo",
+	Short: "Manage geo data",
+}
+
+// RefreshCmd re-geolocates all stored nodes with the current geo database
+var RefreshCmd = &cobra.Command{
+	Use:   "refresh",
+	Short: "Re-geolocates all stored nodes with the current geo database",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := config.Load(globalArgs.ConfigPath, &cfg); err != nil {
+			log.Fatal("Error reading config file", err)
+		}
+
+		logger, err := logger.Create(globalArgs.LogLevel)
+		if err != nil {
+			fmt.Println("Error initializing logger")
+		}
+		defer logger.Sync()
+
+		if download {
+			geoUpdater := geodata.NewUpdater(&cfg.GeoDataUpdater, cfg.GeoDataDbPath, logger)
+			if !geoUpdater.Enabled() {
+				logger.Fatal("MaxMind license key is required to download geo data")
+			}
+			if _, err := geoUpdater.Update(cmd.Context()); err != nil {
+				logger.Fatal("Error downloading geo database", zap.Error(err))
+			}
+		}
+
+		store, err := db.Open(cfg.DbDriver, cfg.DbPath, cfg.DbDSN)
+		if err != nil {
+			logger.Fatal("Error connecting to database", zap.Error(err))
+		}
+		defer store.Close()
+
+		geoProvider, err := geodata.NewProvider(&cfg.GeoProviders, cfg.GeoDataDbPath)
+		if err != nil {
+			logger.Fatal("Error connecting to geo database", zap.Error(err))
+		}
+		defer geoProvider.Close()
+
+		nodes, err := store.ListNodeData(false)
+		if err != nil {
+			logger.Fatal("Error listing nodes", zap.Error(err))
+		}
+
+		updated := 0
+		for _, node := range nodes {
+			ip := net.ParseIP(node.IPAddress)
+			if ip == nil {
+				continue
+			}
+			location, err := geoProvider.Lookup(ip)
+			if err != nil {
+				logger.Warn("could not get geo data from ip address", zap.String("operatorId", node.OperatorID), zap.Error(err))
+				continue
+			}
+			if err := store.UpdateNodeGeoData(node.OperatorID, location.NodeGeoData()); err != nil {
+				logger.Error("could not update node geo data", zap.String("operatorId", node.OperatorID), zap.Error(err))
+				continue
+			}
+			updated++
+		}
+
+		logger.Info("refreshed geo data", zap.Int("nodes", len(nodes)), zap.Int("updated", updated))
+	},
+}
+
+func init() {
+	args.ProcessArgs(&globalArgs, GeoCmd)
+	RefreshCmd.Flags().BoolVar(&download, "download", false, "Download the latest geo database before refreshing")
+	GeoCmd.AddCommand(RefreshCmd)
+}
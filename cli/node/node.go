@@ -1,48 +1,52 @@
 package node
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"os"
 
-	"github.com/ilyakaznacheev/cleanenv"
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
 
 	forksprotocol "github.com/bloxapp/ssv/protocol/forks"
 	"github.com/bloxapp/ssv/utils"
 	"github.com/bloxapp/ssv/utils/format"
+	"github.com/stakestar/startracker/alerts"
 	"github.com/stakestar/startracker/api"
 	"github.com/stakestar/startracker/cli/args"
+	"github.com/stakestar/startracker/cli/config"
 	"github.com/stakestar/startracker/db"
 	"github.com/stakestar/startracker/eth"
 	"github.com/stakestar/startracker/geodata"
 	"github.com/stakestar/startracker/keys"
+	"github.com/stakestar/startracker/lifecycle"
 	"github.com/stakestar/startracker/logger"
 	"github.com/stakestar/startracker/p2p"
+	"github.com/stakestar/startracker/versions"
 )
 
-type config struct {
-	P2pNetworkConfig p2p.Config `yaml:"p2p"`
-	DbPath           string     `yaml:"dbPath" env:"DB_PATH" env-description:"Path to database file" env-default:"data/nodes.db"`
-	GeoDataDbPath    string     `yaml:"geoDataDbPath" env:"GEO_DATA_DB_PATH" env-description:"Path to geo data database file" env-default:"GeoLite2-City.mmdb"`
-	EventsConfig     eth.Config `yaml:"eventsConfig"`
-}
-
-var cfg config
+var cfg config.Config
 
 var globalArgs args.GlobalArgs
 
+// run modes, api mode serves a shared store or a bolt snapshot without crawling
+const (
+	modeAll     = "all"
+	modeCrawler = "crawler"
+	modeAPI     = "api"
+)
+
+var mode string
+
 // StartNodeCmd is the command to start SSV tracker
 var StartNodeCmd = &cobra.Command{
 	Use:   "start-node",
 	Short: "Starts an instance of SSV node",
 	Run: func(cmd *cobra.Command, args []string) {
-		if globalArgs.ConfigPath != "" {
-			if err := cleanenv.ReadConfig(globalArgs.ConfigPath, &cfg); err != nil {
-				log.Fatal("Error reading config file", err)
-			}
-		} else {
-			log.Fatal("Config path is required")
+		if err := config.Load(globalArgs.ConfigPath, &cfg); err != nil {
+			log.Fatal("Error reading config file", err)
 		}
 
 		logger, err := logger.Create(globalArgs.LogLevel)
@@ -51,73 +55,170 @@ var StartNodeCmd = &cobra.Command{
 		}
 		defer logger.Sync()
 
-		boltDb, err := db.NewBoltDB(cfg.DbPath)
-		if err != nil {
-			logger.Fatal("Error connecting to database", zap.Error(err))
+		switch mode {
+		case modeAll, modeCrawler, modeAPI:
+		default:
+			logger.Fatal("Unknown mode", zap.String("mode", mode))
 			return
 		}
-		defer boltDb.Close()
-
-		geoDb, err := geodata.NewGeoIP2DB(cfg.GeoDataDbPath)
-		if err != nil {
-			logger.Fatal("Error connecting to geo database", zap.Error(err))
-			return
+		logger.Info("Starting startracker", zap.String("mode", mode))
+
+		lc := lifecycle.New(cmd.Context(), cfg.ShutdownTimeout, logger)
+		defer lc.Shutdown()
+		// fail stops the components started so far before exiting, logger.Fatal would skip the deferred shutdown
+		fail := func(msg string, err error) {
+			logger.Error(msg, zap.Error(err))
+			lc.Shutdown()
+			_ = logger.Sync()
+			os.Exit(1)
 		}
-		defer geoDb.Close()
 
-		events, err := eth.NewEthEvents(cmd.Context(), &cfg.EventsConfig, boltDb, logger)
-		if err != nil {
-			logger.Fatal("Error connecting to eth events", zap.Error(err))
-			return
-		}
-		err = events.Start()
-		if err != nil {
-			logger.Fatal("Error starting eth events", zap.Error(err))
-			return
-		}
-		err = events.FetchAddedOperatorEvents()
-		if err != nil {
-			logger.Fatal("Error fetching added operator events", zap.Error(err))
-			return
+		var store db.Store
+		if mode == modeAPI {
+			store, err = openReadOnlyStore(lc, logger)
+		} else {
+			store, err = db.Open(cfg.DbDriver, cfg.DbPath, cfg.DbDSN)
 		}
-		err = events.ListenAddedOperatorEvents()
 		if err != nil {
-			logger.Fatal("Error fetching added operator events", zap.Error(err))
-			return
+			fail("Error connecting to database", err)
 		}
+		// the store is stopped last, after every component writing to it
+		lc.Closer("db", store)
 
-		forkVersion := forksprotocol.GenesisForkVersion
-
-		cfg.P2pNetworkConfig.Ctx = cmd.Context()
-
-		_, operatorPublicKey, err := keys.GenerateKeys()
+		versionTracker, err := versions.NewTracker(&cfg.Versions, store, logger)
 		if err != nil {
-			logger.Fatal("failed to generate operator keys", zap.Error(err))
+			fail("Error configuring version tracking", err)
 		}
 
-		p2pNetwork := setupP2P(forkVersion, operatorPublicKey, boltDb, geoDb, logger)
+		if mode != modeAPI {
+			lc.Go("versions", versionTracker.Run)
 
-		if err := p2pNetwork.Setup(); err != nil {
-			logger.Fatal("failed to setup network", zap.Error(err))
-		}
-		if err := p2pNetwork.Start(); err != nil {
-			logger.Fatal("failed to start network", zap.Error(err))
+			if err := startCrawler(lc, store, logger); err != nil {
+				if lc.Context().Err() != nil {
+					return
+				}
+				fail("Error starting crawler", err)
+			}
 		}
 
-		api := api.New(logger, boltDb)
-		api.Start()
+		if mode != modeCrawler {
+			if mode == modeAPI {
+				cfg.ApiConfig.ReadOnly = true
+			}
+			if cfg.ApiConfig.Network == "" {
+				cfg.ApiConfig.Network = cfg.P2pNetworkConfig.NetworkID
+			}
+			api := api.New(logger, store, &cfg.ApiConfig, versionTracker)
+			go api.Start()
+			lc.OnStop("api", api.Shutdown)
+		}
 
+		lc.Wait()
 	},
 }
 
 func init() {
 	args.ProcessArgs(&globalArgs, StartNodeCmd)
+	StartNodeCmd.Flags().StringVar(&mode, "mode", modeAll, "Run mode (all, crawler, api)")
+}
+
+// openReadOnlyStore opens the store for api mode, bolt files are opened as snapshots and reopened when replaced
+func openReadOnlyStore(lc *lifecycle.Manager, logger *zap.Logger) (db.Store, error) {
+	if cfg.DbDriver != "" && cfg.DbDriver != db.DriverBolt {
+		return db.Open(cfg.DbDriver, cfg.DbPath, cfg.DbDSN)
+	}
+	snapshot, err := db.NewSnapshotStore(cfg.DbPath)
+	if err != nil {
+		return nil, err
+	}
+	lc.Go("db snapshot watcher", func(ctx context.Context) {
+		snapshot.Watch(ctx, cfg.DbSnapshotReloadInterval, logger)
+	})
+	return snapshot, nil
+}
+
+// startCrawler starts alerts, geo data, eth events and p2p crawling and registers them for shutdown
+func startCrawler(lc *lifecycle.Manager, store db.Store, logger *zap.Logger) error {
+	ctx := lc.Context()
+
+	alerter, err := alerts.New(&cfg.Alerts, store, logger)
+	if err != nil {
+		return errors.Wrap(err, "could not configure alerts")
+	}
+	if alerter.Enabled() {
+		store.AddNodeDataListener(alerter.OnNodeData)
+		alerter.Start(ctx)
+		// registered before the crawler components, so it stops after them and delivers their last alerts
+		lc.OnStop("alerts", alerter.Stop)
+	}
+
+	geoProvider, err := geodata.NewProvider(&cfg.GeoProviders, cfg.GeoDataDbPath)
+	if err != nil {
+		return errors.Wrap(err, "could not connect to geo database")
+	}
+	lc.OnStop("geo data", func(context.Context) error {
+		geoProvider.Close()
+		return nil
+	})
+
+	if watcher, ok := geoProvider.(geodata.Watcher); ok {
+		lc.Go("geo data watcher", func(ctx context.Context) {
+			watcher.Watch(ctx, cfg.GeoDataUpdater.WatchInterval, logger)
+		})
+	}
+
+	geoUpdater := geodata.NewUpdater(&cfg.GeoDataUpdater, cfg.GeoDataDbPath, logger)
+	if geoUpdater.Enabled() {
+		lc.Go("geo data updater", geoUpdater.Run)
+	}
+
+	events, err := eth.NewEthEvents(ctx, &cfg.EventsConfig, store, logger)
+	if err != nil {
+		return errors.Wrap(err, "could not connect to eth events")
+	}
+	err = events.Start()
+	if err != nil {
+		return errors.Wrap(err, "could not start eth events")
+	}
+	lc.Closer("eth events", events)
+	err = events.FetchAddedOperatorEvents()
+	if err != nil {
+		return errors.Wrap(err, "could not fetch added operator events")
+	}
+	err = events.ListenAddedOperatorEvents()
+	if err != nil {
+		return errors.Wrap(err, "could not listen to added operator events")
+	}
+
+	forkVersion := forksprotocol.GenesisForkVersion
+
+	cfg.P2pNetworkConfig.Ctx = ctx
+
+	_, operatorPublicKey, err := keys.GenerateKeys()
+	if err != nil {
+		return errors.Wrap(err, "could not generate operator keys")
+	}
+
+	p2pNetwork, err := setupP2P(forkVersion, operatorPublicKey, store, geoProvider, logger)
+	if err != nil {
+		return err
+	}
+
+	if err := p2pNetwork.Setup(); err != nil {
+		return errors.Wrap(err, "could not setup network")
+	}
+	lc.Closer("p2p", p2pNetwork)
+	if err := p2pNetwork.Start(); err != nil {
+		return errors.Wrap(err, "could not start network")
+	}
+
+	return nil
 }
 
-func setupP2P(forkVersion forksprotocol.ForkVersion, operatorPubKey string, db *db.BoltDB, geodata *geodata.GeoIP2DB, logger *zap.Logger) p2p.P2PNetwork {
+func setupP2P(forkVersion forksprotocol.ForkVersion, operatorPubKey string, db db.Store, geoProvider geodata.Provider, logger *zap.Logger) (p2p.P2PNetwork, error) {
 	netPrivKey, err := utils.ECDSAPrivateKey(logger, "")
 	if err != nil {
-		logger.Fatal("failed to setup network private key", zap.Error(err))
+		return nil, errors.Wrap(err, "could not setup network private key")
 	}
 
 	fmt.Println(operatorPubKey)
@@ -128,8 +229,8 @@ func setupP2P(forkVersion forksprotocol.ForkVersion, operatorPubKey string, db *
 	cfg.P2pNetworkConfig.ForkVersion = forkVersion
 	cfg.P2pNetworkConfig.OperatorID = format.OperatorID([]byte(operatorPubKey))
 	cfg.P2pNetworkConfig.DB = db
-	cfg.P2pNetworkConfig.GeoData = geodata
+	cfg.P2pNetworkConfig.GeoData = geoProvider
 	cfg.P2pNetworkConfig.MaxPeers = 500
 
-	return p2p.New(&cfg.P2pNetworkConfig)
+	return p2p.New(&cfg.P2pNetworkConfig), nil
 }
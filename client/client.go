@@ -0,0 +1,377 @@
+// Package client is a typed client of the StarTracker v1 API, see api/openapi.json
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+type Config struct {
+	// BaseURL is the address of the tracker, e.g. https://tracker.example.com
+	BaseURL string
+	// AdminToken is sent to the admin endpoints
+	AdminToken string
+	// HTTPClient defaults to http.DefaultClient
+	HTTPClient *http.Client
+}
+
+type Client struct {
+	baseURL    string
+	adminToken string
+	httpClient *http.Client
+}
+
+func New(config *Config) *Client {
+	httpClient := config.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{
+		baseURL:    strings.TrimSuffix(config.BaseURL, "/"),
+		adminToken: config.AdminToken,
+		httpClient: httpClient,
+	}
+}
+
+// pageLimit is the page size used to fetch lists, the maximum accepted by the API
+const pageLimit = 1000
+
+// Error is a non 2xx response of the API, Code is machine readable (e.g. not_found, rate_limited)
+type Error struct {
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("startracker api: %d %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// IsNotFound reports whether err is a 404 response
+func IsNotFound(err error) bool {
+	apiErr, ok := err.(*Error)
+	return ok && apiErr.StatusCode == http.StatusNotFound
+}
+
+func (c *Client) request(ctx context.Context, method, path string, query url.Values, body interface{}, admin bool) (*http.Response, error) {
+	u := c.baseURL + "/api/v1" + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(encoded)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if admin {
+		req.Header.Set("Authorization", "Bearer "+c.adminToken)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		apiErr := &Error{StatusCode: resp.StatusCode}
+		var errorBody struct {
+			Error struct {
+				Code    string `json:"code"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&errorBody); err == nil {
+			apiErr.Code = errorBody.Error.Code
+			apiErr.Message = errorBody.Error.Message
+		}
+		return nil, apiErr
+	}
+	return resp, nil
+}
+
+// do sends the request and decodes the data of the response envelope into result
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body interface{}, admin bool, result interface{}) error {
+	resp, err := c.request(ctx, method, path, query, body, admin)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	envelope := struct {
+		Data interface{} `json:"data"`
+	}{Data: result}
+	return json.NewDecoder(resp.Body).Decode(&envelope)
+}
+
+func (c *Client) get(ctx context.Context, path string, query url.Values, result interface{}) error {
+	return c.do(ctx, http.MethodGet, path, query, nil, false, result)
+}
+
+// list fetches every page of a list into items, and the metadata of the last page into metadata
+func list[T any](ctx context.Context, c *Client, path string, query url.Values, admin bool, items *[]T, metadata interface{}) error {
+	pageQuery := url.Values{}
+	for k, v := range query {
+		pageQuery[k] = v
+	}
+	pageQuery.Set("limit", strconv.Itoa(pageLimit))
+	*items = []T{}
+	for {
+		pageQuery.Set("offset", strconv.Itoa(len(*items)))
+		resp, err := c.request(ctx, http.MethodGet, path, pageQuery, nil, admin)
+		if err != nil {
+			return err
+		}
+		var page struct {
+			Data       []T             `json:"data"`
+			Pagination Pagination      `json:"pagination"`
+			Metadata   json.RawMessage `json:"metadata"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+		*items = append(*items, page.Data...)
+		if len(page.Data) == 0 || len(*items) >= page.Pagination.Total {
+			return json.Unmarshal(page.Metadata, metadata)
+		}
+	}
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+func boundsQuery(query url.Values, bounds Bounds) {
+	query.Set("min_lat", formatFloat(bounds.MinLatitude))
+	query.Set("min_lon", formatFloat(bounds.MinLongitude))
+	query.Set("max_lat", formatFloat(bounds.MaxLatitude))
+	query.Set("max_lon", formatFloat(bounds.MaxLongitude))
+}
+
+// Nodes lists the nodes of known operators
+func (c *Client) Nodes(ctx context.Context) (*NodeList, error) {
+	var result NodeList
+	if err := list(ctx, c, "/nodes", nil, false, &result.Nodes, &result.Metadata); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// AllNodes lists all crawled nodes
+func (c *Client) AllNodes(ctx context.Context) (*NodeList, error) {
+	var result NodeList
+	if err := list(ctx, c, "/nodes/all", nil, false, &result.Nodes, &result.Metadata); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// NodesInCIDR lists the nodes of known operators with an IP in the network, it fails with 403 when IPs are private
+func (c *Client) NodesInCIDR(ctx context.Context, cidr string) (*NodeList, error) {
+	var result NodeList
+	if err := list(ctx, c, "/nodes", url.Values{"cidr": {cidr}}, false, &result.Nodes, &result.Metadata); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// NodesByIP lists the nodes announcing the IP, it fails with 403 when IPs are private
+func (c *Client) NodesByIP(ctx context.Context, ip string) (*NodeList, error) {
+	var result NodeList
+	if err := list(ctx, c, "/nodes/ip/"+url.PathEscape(ip), nil, false, &result.Nodes, &result.Metadata); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *Client) NodeByPubKey(ctx context.Context, pubkey string) (*Node, error) {
+	var result Node
+	if err := c.get(ctx, "/nodes/pubkey/"+url.PathEscape(pubkey), nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *Client) NodeByOperatorID(ctx context.Context, operatorID uint64) (*Node, error) {
+	var result Node
+	if err := c.get(ctx, "/nodes/operatorid/"+strconv.FormatUint(operatorID, 10), nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// NodesNear lists the nodes within radiusKm of the point, nearest first
+func (c *Client) NodesNear(ctx context.Context, latitude, longitude, radiusKm float64) (*NodeDistanceList, error) {
+	var result NodeDistanceList
+	query := url.Values{
+		"lat":    {formatFloat(latitude)},
+		"lon":    {formatFloat(longitude)},
+		"radius": {formatFloat(radiusKm)},
+	}
+	if err := list(ctx, c, "/nodes/near", query, false, &result.Nodes, &result.Metadata); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// NodesInBox lists the nodes inside the bounds, with their distance to the center
+func (c *Client) NodesInBox(ctx context.Context, bounds Bounds) (*NodeDistanceList, error) {
+	var result NodeDistanceList
+	query := url.Values{}
+	boundsQuery(query, bounds)
+	if err := list(ctx, c, "/nodes/bbox", query, false, &result.Nodes, &result.Metadata); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Clusters returns the node clusters of the zoom level, inside the bounds if not nil
+func (c *Client) Clusters(ctx context.Context, zoom int, bounds *Bounds) (*ClusterList, error) {
+	var result ClusterList
+	query := url.Values{"zoom": {strconv.Itoa(zoom)}}
+	if bounds != nil {
+		boundsQuery(query, *bounds)
+	}
+	if err := list(ctx, c, "/nodes/clusters", query, false, &result.Clusters, &result.Metadata); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ClusterTile returns the node clusters of a map tile as a Mapbox vector tile
+func (c *Client) ClusterTile(ctx context.Context, z, x, y int) ([]byte, error) {
+	resp, err := c.request(ctx, http.MethodGet, fmt.Sprintf("/tiles/%d/%d/%d.mvt", z, x, y), nil, nil, false)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+// SubmitLocationClaim submits a claim signed with the operator key and returns the stored claim
+func (c *Client) SubmitLocationClaim(ctx context.Context, claim *LocationClaim) (*LocationClaim, error) {
+	var result LocationClaim
+	path := "/nodes/operatorid/" + strconv.FormatUint(claim.OperatorID, 10) + "/claim"
+	if err := c.do(ctx, http.MethodPost, path, nil, claim, false, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *Client) Versions(ctx context.Context) (*Versions, error) {
+	var result Versions
+	if err := c.get(ctx, "/versions", nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Operators lists the registered operators, with the given status if not empty
+func (c *Client) Operators(ctx context.Context, status string) (*OperatorList, error) {
+	var result OperatorList
+	query := url.Values{}
+	if status != "" {
+		query.Set("status", status)
+	}
+	if err := list(ctx, c, "/operators", query, false, &result.Operators, &result.Metadata); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *Client) Operator(ctx context.Context, operatorID uint64) (*Operator, error) {
+	var result Operator
+	if err := c.get(ctx, "/operators/"+strconv.FormatUint(operatorID, 10), nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Entities lists the operator groups with at least minOperators operators, the server default is used if 0
+func (c *Client) Entities(ctx context.Context, minOperators int) (*EntityList, error) {
+	var result EntityList
+	query := url.Values{}
+	if minOperators > 0 {
+		query.Set("min_operators", strconv.Itoa(minOperators))
+	}
+	if err := list(ctx, c, "/entities", query, false, &result.Entities, &result.Metadata); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *Client) Stats(ctx context.Context) (*Stats, error) {
+	var result Stats
+	if err := c.get(ctx, "/stats", nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// AdminNodes lists all nodes with IPs and exact coordinates
+func (c *Client) AdminNodes(ctx context.Context) (*NodeList, error) {
+	var result NodeList
+	if err := list(ctx, c, "/admin/nodes", nil, true, &result.Nodes, &result.Metadata); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *Client) AdminNodesInCIDR(ctx context.Context, cidr string) (*NodeList, error) {
+	var result NodeList
+	if err := list(ctx, c, "/admin/nodes", url.Values{"cidr": {cidr}}, true, &result.Nodes, &result.Metadata); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *Client) AdminNodesByIP(ctx context.Context, ip string) (*NodeList, error) {
+	var result NodeList
+	if err := list(ctx, c, "/admin/nodes/ip/"+url.PathEscape(ip), nil, true, &result.Nodes, &result.Metadata); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *Client) AdminNodeByPubKey(ctx context.Context, pubkey string) (*Node, error) {
+	var result Node
+	if err := c.do(ctx, http.MethodGet, "/admin/nodes/pubkey/"+url.PathEscape(pubkey), nil, nil, true, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *Client) AdminNodeByOperatorID(ctx context.Context, operatorID uint64) (*Node, error) {
+	var result Node
+	if err := c.do(ctx, http.MethodGet, "/admin/nodes/operatorid/"+strconv.FormatUint(operatorID, 10), nil, nil, true, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// AdminSnapshot writes a database snapshot in the format (jsonl or bolt) to w
+func (c *Client) AdminSnapshot(ctx context.Context, format string, w io.Writer) error {
+	resp, err := c.request(ctx, http.MethodGet, "/admin/snapshot", url.Values{"format": {format}}, nil, true)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
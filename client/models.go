@@ -0,0 +1,219 @@
+package client
+
+import "time"
+
+// Metadata is sent with every response
+type Metadata struct {
+	Network     string    `json:"network"`
+	GeneratedAt time.Time `json:"generated_at"`
+}
+
+type Pagination struct {
+	Offset int `json:"offset"`
+	Limit  int `json:"limit"`
+	Total  int `json:"total"`
+}
+
+type GeoData struct {
+	CountryCode    string  `json:"country_code"`
+	CountryName    string  `json:"country_name"`
+	City           string  `json:"city"`
+	Latitude       float64 `json:"latitude"`
+	Longitude      float64 `json:"longitude"`
+	AccuracyRadius uint16  `json:"accuracy_radius"`
+	ASN            uint32  `json:"asn,omitempty"`
+	Organization   string  `json:"organization,omitempty"`
+	Provider       string  `json:"provider,omitempty"`
+}
+
+// LocationClaim is a location submitted by an operator, Signature signs the claim message with the operator key
+type LocationClaim struct {
+	OperatorID  uint64  `json:"operator_id"`
+	CountryCode string  `json:"country_code"`
+	City        string  `json:"city"`
+	Latitude    float64 `json:"latitude"`
+	Longitude   float64 `json:"longitude"`
+	Timestamp   int64   `json:"timestamp"`
+	Signature   string  `json:"signature"`
+	Verified    bool    `json:"verified"`
+}
+
+// FeeChange is an operator fee event, Fee is in wei per block
+type FeeChange struct {
+	Type  string `json:"type"`
+	Block uint64 `json:"block"`
+	Fee   string `json:"fee,omitempty"`
+}
+
+// OperatorInfo is the contract data of an operator, fees are in wei per block
+type OperatorInfo struct {
+	OperatorID        uint64      `json:"operator_id"`
+	PublicKey         string      `json:"public_key"`
+	Owner             string      `json:"owner,omitempty"`
+	Fee               string      `json:"fee,omitempty"`
+	DeclaredFee       string      `json:"declared_fee,omitempty"`
+	FeeHistory        []FeeChange `json:"fee_history"`
+	RegistrationBlock uint64      `json:"registration_block,omitempty"`
+}
+
+// Node is a crawled node, OperatorID is the contract operator id (0 if not known yet)
+// and Operator is only set by single node lookups
+type Node struct {
+	UpdatedAt     time.Time      `json:"updated_at"`
+	IPAddress     string         `json:"ip_address,omitempty"`
+	AddressFamily string         `json:"address_family"`
+	GeoData       GeoData        `json:"geo_data"`
+	NodeVersion   string         `json:"node_version"`
+	OperatorID    uint64         `json:"operator_id"`
+	ClaimedGeo    *LocationClaim `json:"claimed_geo,omitempty"`
+	Outdated      bool           `json:"outdated"`
+	Operator      *OperatorInfo  `json:"operator,omitempty"`
+}
+
+// ColocatedHost is an IP announced by the nodes of several operators
+type ColocatedHost struct {
+	IPAddress   string   `json:"ip_address"`
+	OperatorIDs []uint64 `json:"operator_ids"`
+}
+
+// NodeList holds all pages of a node listing, with the metadata of the last page
+type NodeList struct {
+	Nodes    []Node
+	Metadata struct {
+		Metadata
+		Count     int             `json:"count"`
+		Colocated []ColocatedHost `json:"colocated,omitempty"`
+	}
+}
+
+type NodeWithDistance struct {
+	Node
+	DistanceKm float64 `json:"distance_km"`
+}
+
+type NodeDistanceList struct {
+	Nodes    []NodeWithDistance
+	Metadata struct {
+		Metadata
+		Count     int     `json:"count"`
+		Lat       float64 `json:"lat,omitempty"`
+		Lon       float64 `json:"lon,omitempty"`
+		RadiusKm  float64 `json:"radius_km,omitempty"`
+		CenterLat float64 `json:"center_lat,omitempty"`
+		CenterLon float64 `json:"center_lon,omitempty"`
+	}
+}
+
+// Bounds is a latitude/longitude box, MinLongitude is greater than MaxLongitude when it crosses the antimeridian
+type Bounds struct {
+	MinLatitude  float64
+	MinLongitude float64
+	MaxLatitude  float64
+	MaxLongitude float64
+}
+
+type Cluster struct {
+	Geohash   string  `json:"geohash"`
+	Count     int     `json:"count"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+type ClusterList struct {
+	Clusters []Cluster
+	Metadata struct {
+		Metadata
+		Count     int `json:"count"`
+		Nodes     int `json:"nodes"`
+		Zoom      int `json:"zoom"`
+		Precision int `json:"precision"`
+	}
+}
+
+// operator statuses
+const (
+	OperatorStatusOnline  = "online"
+	OperatorStatusOffline = "offline"
+	OperatorStatusNotSeen = "not_seen"
+)
+
+// Operator is a registered operator with its node, if it was ever seen
+type Operator struct {
+	OperatorInfo
+	Status string `json:"status"`
+	Node   *Node  `json:"node,omitempty"`
+}
+
+type OperatorList struct {
+	Operators []Operator
+	Metadata  struct {
+		Metadata
+		Count      int            `json:"count"`
+		Total      int            `json:"total"`
+		Statuses   map[string]int `json:"statuses"`
+		OnlineRate float64        `json:"online_rate"`
+	}
+}
+
+type EntityLocation struct {
+	CountryCode string `json:"country_code"`
+	City        string `json:"city"`
+	Nodes       int    `json:"nodes"`
+}
+
+// Entity is a group of operators likely controlled by the same party
+type Entity struct {
+	ID        string           `json:"id"`
+	Operators []uint64         `json:"operators"`
+	Owners    []string         `json:"owners"`
+	Reasons   []string         `json:"reasons"`
+	Nodes     int              `json:"nodes"`
+	Share     float64          `json:"share"`
+	Locations []EntityLocation `json:"locations"`
+	ASNs      []uint32         `json:"asns"`
+}
+
+type EntityList struct {
+	Entities []Entity
+	Metadata struct {
+		Metadata
+		Count int `json:"count"`
+	}
+}
+
+// Concentration measures how the nodes are spread over groups,
+// Nakamoto is the smallest number of groups running more than a third of the nodes
+type Concentration struct {
+	Groups   int     `json:"groups"`
+	Nakamoto int     `json:"nakamoto"`
+	HHI      float64 `json:"hhi"`
+	TopShare float64 `json:"top_share"`
+}
+
+type Stats struct {
+	Nodes     int           `json:"nodes"`
+	Operators int           `json:"operators"`
+	Entities  Concentration `json:"entities"`
+	Countries Concentration `json:"countries"`
+	ASNs      Concentration `json:"asns"`
+}
+
+type VersionCount struct {
+	Version  string  `json:"version"`
+	Count    int     `json:"count"`
+	Share    float64 `json:"share"`
+	Outdated bool    `json:"outdated"`
+}
+
+type VersionAdoption struct {
+	Date        string         `json:"date"`
+	Total       int            `json:"total"`
+	LatestShare float64        `json:"latest_share"`
+	Versions    []VersionCount `json:"versions"`
+}
+
+type Versions struct {
+	Latest  string            `json:"latest"`
+	Current VersionAdoption   `json:"current"`
+	History []VersionAdoption `json:"history"`
+}
@@ -0,0 +1,85 @@
+package connections
+
+import (
+	"context"
+	"net"
+	"time"
+
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/pkg/errors"
+	"github.com/stakestar/startracker/db"
+)
+
+const (
+	// dnsResolveTimeout is the timeout used when resolving dns multiaddrs
+	dnsResolveTimeout = 5 * time.Second
+)
+
+// errRelayedAddress is thrown when the remote address goes through a circuit relay,
+// in which case the address belongs to the relay and not to the node itself
+var errRelayedAddress = errors.New("address is relayed through a circuit relay")
+
+// remoteAddress is the ip address of a remote peer along with its family
+type remoteAddress struct {
+	IP     net.IP
+	Family string
+}
+
+// getIPAddressFromMultiaddr extracts the ip address of a peer from its multiaddr,
+// it supports ip4, ip6 and dns (dns, dns4, dns6) addresses and rejects relayed addresses
+func (h *handshaker) getIPAddressFromMultiaddr(maddr ma.Multiaddr) (*remoteAddress, error) {
+	if maddr == nil {
+		return nil, errors.New("empty multiaddr")
+	}
+	if _, err := maddr.ValueForProtocol(ma.P_CIRCUIT); err == nil {
+		return nil, errRelayedAddress
+	}
+
+	first, _ := ma.SplitFirst(maddr)
+	if first == nil {
+		return nil, errors.New("empty multiaddr")
+	}
+
+	switch code := first.Protocol().Code; code {
+	case ma.P_IP4, ma.P_IP6:
+		ip := net.ParseIP(first.Value())
+		if ip == nil {
+			return nil, errors.Errorf("invalid ip address in multiaddr: %s", maddr.String())
+		}
+		return newRemoteAddress(ip), nil
+	case ma.P_DNS, ma.P_DNS4, ma.P_DNS6:
+		return h.resolveDNSAddress(first.Value(), code)
+	default:
+		return nil, errors.Errorf("unsupported multiaddr protocol %s in %s", first.Protocol().Name, maddr.String())
+	}
+}
+
+// resolveDNSAddress resolves the given host and returns the first ip that matches the dns protocol family
+func (h *handshaker) resolveDNSAddress(host string, code int) (*remoteAddress, error) {
+	ctx, cancel := context.WithTimeout(h.ctx, dnsResolveTimeout)
+	defer cancel()
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not resolve %s", host)
+	}
+	for _, addr := range addrs {
+		isIPv4 := addr.IP.To4() != nil
+		if (code == ma.P_DNS4 && !isIPv4) || (code == ma.P_DNS6 && isIPv4) {
+			continue
+		}
+		return newRemoteAddress(addr.IP), nil
+	}
+	return nil, errors.Errorf("no matching ip address found for %s", host)
+}
+
+func newRemoteAddress(ip net.IP) *remoteAddress {
+	family := db.AddressFamilyIPv6
+	if ip.To4() != nil {
+		family = db.AddressFamilyIPv4
+	}
+	return &remoteAddress{
+		IP:     ip,
+		Family: family,
+	}
+}
@@ -55,8 +55,8 @@ type handshaker struct {
 	connIdx     peers.ConnectionIndex
 	ids         identify.IDService
 	net         libp2pnetwork.Network
-	db          *db.BoltDB
-	geodata     *geodata.GeoIP2DB
+	db          db.Store
+	geodata     geodata.Provider
 }
 
 // HandshakerCfg is the configuration for creating an handshaker instance
@@ -71,7 +71,7 @@ type HandshakerCfg struct {
 }
 
 // NewHandshaker creates a new instance of handshaker
-func NewHandshaker(ctx context.Context, cfg *HandshakerCfg, db *db.BoltDB, geoData *geodata.GeoIP2DB) Handshaker {
+func NewHandshaker(ctx context.Context, cfg *HandshakerCfg, db db.Store, geoData geodata.Provider) Handshaker {
 	h := &handshaker{
 		ctx:         ctx,
 		logger:      cfg.Logger.With(zap.String("where", "Handshaker")),
@@ -128,35 +128,53 @@ func (h *handshaker) Handler(logger *zap.Logger) libp2pnetwork.StreamHandler {
 }
 
 func (h *handshaker) processIncomingNodeInfo(maddr ma.Multiaddr, ni records.NodeInfo) {
-	ip, err := h.getIPAddressFromMultiaddr(maddr)
-	if err != nil {
-		h.logger.Warn("could not get ip address from multiaddr", zap.Error(err))
+	if ni.Metadata == nil || ni.Metadata.OperatorID == "" {
+		return
+	}
+
+	nodeData := &db.NodeData{
+		NodeVersion: ni.Metadata.NodeVersion,
+		OperatorID:  ni.Metadata.OperatorID,
 	}
 
-	ipGeoData, err := h.geodata.GetGeoDataFromIPAddress(ip)
+	addr, err := h.getIPAddressFromMultiaddr(maddr)
 	if err != nil {
-		h.logger.Warn("could not get geo data from ip address", zap.Error(err))
+		h.logger.Warn("could not get ip address from multiaddr, keeping the last known location", zap.Error(err))
+		if !h.keepLocation(nodeData) {
+			return
+		}
+	} else if location, err := h.geodata.Lookup(addr.IP); err != nil {
+		h.logger.Warn("could not get geo data from ip address, keeping the last known location", zap.Error(err))
+		if !h.keepLocation(nodeData) {
+			return
+		}
+	} else {
+		nodeData.IPAddress = addr.IP.String()
+		nodeData.AddressFamily = addr.Family
+		nodeData.GeoData = location.NodeGeoData()
 	}
 
-	geoData := &db.GeoData{
-		City:           ipGeoData.City.Names["en"],
-		CountryName:    ipGeoData.Country.Names["en"],
-		CountryCode:    ipGeoData.Country.IsoCode,
-		Latitude:       ipGeoData.Location.Latitude,
-		Longitude:      ipGeoData.Location.Longitude,
-		AccuracyRadius: ipGeoData.Location.AccuracyRadius,
+	h.logger.Info("Node Data", zap.Any("data", nodeData))
+	if err := h.db.StoreNodeData(nodeData); err != nil {
+		h.logger.Warn("could not store node data", zap.Error(err))
 	}
+}
 
-	if ni.Metadata.OperatorID != "" {
-		nodeData := &db.NodeData{
-			IPAddress:   ip,
-			GeoData:     *geoData,
-			NodeVersion: ni.Metadata.NodeVersion,
-			OperatorID:  ni.Metadata.OperatorID,
-		}
-		h.logger.Info("Node Data", zap.Any("data", nodeData))
-		h.db.StoreNodeData(nodeData)
+// keepLocation copies the stored ip and geo data of the node, so that only its version and update time change.
+// It returns false if the stored node could not be read, the node must not be stored then
+func (h *handshaker) keepLocation(nodeData *db.NodeData) bool {
+	stored, err := h.db.GetNodeData(nodeData.OperatorID)
+	if err == db.ErrNotFound {
+		return true
+	}
+	if err != nil {
+		h.logger.Warn("could not get stored node data", zap.Error(err))
+		return false
 	}
+	nodeData.IPAddress = stored.IPAddress
+	nodeData.AddressFamily = stored.AddressFamily
+	nodeData.GeoData = stored.GeoData
+	return true
 }
 
 // preHandshake makes sure that we didn't reach peers limit and have exchanged framework information (libp2p)
@@ -284,9 +302,3 @@ func (h *handshaker) nodeInfoFromUserAgent(logger *zap.Logger, conn libp2pnetwor
 	}
 	return ni, nil
 }
-
-func (h *handshaker) getIPAddressFromMultiaddr(maddr ma.Multiaddr) (string, error) {
-	ip, _ := ma.SplitFirst(maddr)
-
-	return ip.Value(), nil
-}
@@ -0,0 +1,63 @@
+package db
+
+import (
+	"encoding/json"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var locationClaimsBucketName = []byte("LocationClaims")
+
+func setupLocationClaimsBucket(db *bolt.DB) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(locationClaimsBucketName)
+		return err
+	})
+}
+
+// SaveLocationClaim stores the location claim of an operator, replacing any previous claim
+func (db *BoltDB) SaveLocationClaim(operatorID string, claim *LocationClaim) error {
+	value, err := json.Marshal(claim)
+	if err != nil {
+		return err
+	}
+	return db.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(locationClaimsBucketName)
+		if err := bucket.Put([]byte(operatorID), value); err != nil {
+			return err
+		}
+		return bumpRevision(tx)
+	})
+}
+
+func (db *BoltDB) GetLocationClaim(operatorID string) (*LocationClaim, error) {
+	var claim *LocationClaim
+	err := db.db.View(func(tx *bolt.Tx) error {
+		var err error
+		claim, err = getLocationClaim(tx, []byte(operatorID))
+		if err != nil {
+			return err
+		}
+		if claim == nil {
+			return ErrNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return claim, nil
+}
+
+// getLocationClaim returns the claim stored for the operator or nil if there is none
+func getLocationClaim(tx *bolt.Tx, operatorID []byte) (*LocationClaim, error) {
+	value := tx.Bucket(locationClaimsBucketName).Get(operatorID)
+	if value == nil {
+		return nil, nil
+	}
+	var claim LocationClaim
+	if err := json.Unmarshal(value, &claim); err != nil {
+		return nil, err
+	}
+	return &claim, nil
+}
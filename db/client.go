@@ -3,19 +3,28 @@ package db
 import (
 	"errors"
 	"fmt"
+	"time"
 
 	bolt "go.etcd.io/bbolt"
 )
 
 var ErrNotFound = errors.New("not found")
 
+// ErrReadOnly is returned by writes to a store opened read-only
+var ErrReadOnly = errors.New("database is read-only")
+
+// NodeDataListener is called after node data is stored or its geo data is updated, previous is nil for new nodes
+type NodeDataListener func(previous, current *NodeData)
+
 type BoltDB struct {
 	db *bolt.DB
+
+	nodeDataListeners []NodeDataListener
 }
 
 func NewBoltDB(dbPath string) (*BoltDB, error) {
 	fmt.Printf("Opening db at %s, if it doesn't exist it will be created\n", dbPath)
-	db, err := bolt.Open(dbPath, 0600, nil)
+	db, err := bolt.Open(dbPath, 0600, &bolt.Options{Timeout: 5 * time.Second})
 	if err != nil {
 		return nil, err
 	}
@@ -31,10 +40,56 @@ func NewBoltDB(dbPath string) (*BoltDB, error) {
 	if err != nil {
 		return nil, err
 	}
+	err = setupLocationClaimsBucket(db)
+	if err != nil {
+		return nil, err
+	}
+	err = setupVersionHistogramsBucket(db)
+	if err != nil {
+		return nil, err
+	}
+	err = setupNodesByIPBucket(db)
+	if err != nil {
+		return nil, err
+	}
+	err = setupNodesByGeohashBucket(db)
+	if err != nil {
+		return nil, err
+	}
+	err = migrate(db)
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return &BoltDB{db: db}, nil
+}
+
+// NewBoltDBReadOnly opens an existing db file without write access, the file must be at the latest schema version
+func NewBoltDBReadOnly(dbPath string) (*BoltDB, error) {
+	db, err := bolt.Open(dbPath, 0600, &bolt.Options{Timeout: 5 * time.Second, ReadOnly: true})
 	if err != nil {
 		return nil, err
 	}
-	return &BoltDB{db}, nil
+	var version uint64
+	err = db.View(func(tx *bolt.Tx) error {
+		if tx.Bucket(stateBucketName) != nil {
+			version = getSchemaVersion(tx)
+		}
+		return nil
+	})
+	if err == nil && version != LatestSchemaVersion() {
+		err = fmt.Errorf("database schema version %d does not match the supported version %d", version, LatestSchemaVersion())
+	}
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return &BoltDB{db: db}, nil
+}
+
+// AddNodeDataListener registers a listener for node data changes, it must be called before the db is used
+func (db *BoltDB) AddNodeDataListener(listener NodeDataListener) {
+	db.nodeDataListeners = append(db.nodeDataListeners, listener)
 }
 
 func (db *BoltDB) Close() error {
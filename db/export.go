@@ -0,0 +1,267 @@
+package db
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"time"
+
+	"github.com/stakestar/startracker/utils"
+	bolt "go.etcd.io/bbolt"
+)
+
+// exportFormatVersion is the version of the json lines export format
+const exportFormatVersion = 1
+
+// export record types
+const (
+	RecordHeader           = "header"
+	RecordNode             = "node"
+	RecordOperator         = "operator"
+	RecordLocationClaim    = "location_claim"
+	RecordVersionHistogram = "version_histogram"
+	RecordLastBlock        = "last_block"
+)
+
+// ExportRecord is a line of an export, Key is the id of the entity when it is not part of Data
+type ExportRecord struct {
+	Type string          `json:"type"`
+	Key  string          `json:"key,omitempty"`
+	Data json.RawMessage `json:"data"`
+}
+
+type exportHeader struct {
+	Version    int       `json:"version"`
+	ExportedAt time.Time `json:"exported_at"`
+}
+
+// ImportStats counts the imported records by type
+type ImportStats map[string]int
+
+type exportWriter struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+func newExportWriter(w io.Writer) (*exportWriter, error) {
+	buffered := bufio.NewWriter(w)
+	e := &exportWriter{w: buffered, enc: json.NewEncoder(buffered)}
+	return e, e.writeValue(RecordHeader, "", exportHeader{Version: exportFormatVersion, ExportedAt: time.Now().UTC()})
+}
+
+func (e *exportWriter) write(recordType, key string, data []byte) error {
+	return e.enc.Encode(ExportRecord{Type: recordType, Key: key, Data: data})
+}
+
+func (e *exportWriter) writeValue(recordType, key string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return e.write(recordType, key, data)
+}
+
+func (e *exportWriter) flush() error {
+	return e.w.Flush()
+}
+
+// importHandler applies the decoded records of an import
+type importHandler struct {
+	node             func(data *NodeData) error
+	operator         func(operator *Operator) error
+	locationClaim    func(operatorID string, claim *LocationClaim) error
+	versionHistogram func(histogram *VersionHistogram) error
+	lastBlock        func(blockNumber *big.Int) error
+}
+
+// readExport decodes an export and passes every record to the handler
+func readExport(r io.Reader, h *importHandler) (ImportStats, error) {
+	stats := make(ImportStats)
+	dec := json.NewDecoder(bufio.NewReader(r))
+	for line := 1; ; line++ {
+		var record ExportRecord
+		if err := dec.Decode(&record); err == io.EOF {
+			if stats[RecordHeader] == 0 {
+				return nil, fmt.Errorf("export is empty")
+			}
+			delete(stats, RecordHeader)
+			return stats, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("record %d: %w", line, err)
+		}
+		if line == 1 && record.Type != RecordHeader {
+			return nil, fmt.Errorf("export has no header")
+		}
+		if err := importRecord(&record, h); err != nil {
+			return nil, fmt.Errorf("record %d (%s %s): %w", line, record.Type, record.Key, err)
+		}
+		stats[record.Type]++
+	}
+}
+
+func importRecord(record *ExportRecord, h *importHandler) error {
+	switch record.Type {
+	case RecordHeader:
+		var header exportHeader
+		if err := json.Unmarshal(record.Data, &header); err != nil {
+			return err
+		}
+		if header.Version != exportFormatVersion {
+			return fmt.Errorf("unsupported export version %d", header.Version)
+		}
+		return nil
+	case RecordNode:
+		var data NodeData
+		if err := json.Unmarshal(record.Data, &data); err != nil {
+			return err
+		}
+		data.OperatorID = record.Key
+		data.ClaimedGeo = nil
+		data.Outdated = false
+		return h.node(&data)
+	case RecordOperator:
+		var operator Operator
+		if err := json.Unmarshal(record.Data, &operator); err != nil {
+			return err
+		}
+		return h.operator(&operator)
+	case RecordLocationClaim:
+		var claim LocationClaim
+		if err := json.Unmarshal(record.Data, &claim); err != nil {
+			return err
+		}
+		return h.locationClaim(record.Key, &claim)
+	case RecordVersionHistogram:
+		var histogram VersionHistogram
+		if err := json.Unmarshal(record.Data, &histogram); err != nil {
+			return err
+		}
+		return h.versionHistogram(&histogram)
+	case RecordLastBlock:
+		var value string
+		if err := json.Unmarshal(record.Data, &value); err != nil {
+			return err
+		}
+		blockNumber, ok := new(big.Int).SetString(value, 10)
+		if !ok {
+			return fmt.Errorf("invalid block number %s", value)
+		}
+		return h.lastBlock(blockNumber)
+	default:
+		return fmt.Errorf("unknown record type")
+	}
+}
+
+// Export writes all data as json lines from a single read transaction
+func (db *BoltDB) Export(w io.Writer) error {
+	e, err := newExportWriter(w)
+	if err != nil {
+		return err
+	}
+	err = db.db.View(func(tx *bolt.Tx) error {
+		buckets := []struct {
+			name       []byte
+			recordType string
+			keyed      bool
+		}{
+			{operatorsBucketName, RecordOperator, false},
+			{nodeDataBucketName, RecordNode, true},
+			{locationClaimsBucketName, RecordLocationClaim, true},
+			{versionHistogramsBucketName, RecordVersionHistogram, false},
+		}
+		for _, b := range buckets {
+			err := tx.Bucket(b.name).ForEach(func(k, v []byte) error {
+				key := ""
+				if b.keyed {
+					key = string(k)
+				}
+				return e.write(b.recordType, key, v)
+			})
+			if err != nil {
+				return err
+			}
+		}
+		if value := tx.Bucket(stateBucketName).Get(lastBlockKey); len(value) == 8 {
+			return e.writeValue(RecordLastBlock, "", new(big.Int).SetUint64(utils.BytesToUint64(value)).String())
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return e.flush()
+}
+
+// Import upserts the records of an export in a single transaction,
+// node data keeps its update time, listeners are not called and the last block only moves forward
+func (db *BoltDB) Import(r io.Reader) (ImportStats, error) {
+	var stats ImportStats
+	err := db.db.Update(func(tx *bolt.Tx) error {
+		var err error
+		stats, err = readExport(r, &importHandler{
+			node: func(data *NodeData) error {
+				value, err := json.Marshal(data)
+				if err != nil {
+					return err
+				}
+				key := []byte(data.OperatorID)
+				if err := reindexNode(tx, key, data); err != nil {
+					return err
+				}
+				return tx.Bucket(nodeDataBucketName).Put(key, value)
+			},
+			operator: func(operator *Operator) error {
+				value, err := json.Marshal(operator)
+				if err != nil {
+					return err
+				}
+				if err := tx.Bucket(operatorsBucketName).Put([]byte(operator.OperatorID), value); err != nil {
+					return err
+				}
+				return tx.Bucket(operatorsContractIdToOperatorIdBucketName).Put(utils.Uint64ToBytes(operator.OperatorIDContract), []byte(operator.OperatorID))
+			},
+			locationClaim: func(operatorID string, claim *LocationClaim) error {
+				value, err := json.Marshal(claim)
+				if err != nil {
+					return err
+				}
+				return tx.Bucket(locationClaimsBucketName).Put([]byte(operatorID), value)
+			},
+			versionHistogram: func(histogram *VersionHistogram) error {
+				value, err := json.Marshal(histogram)
+				if err != nil {
+					return err
+				}
+				return tx.Bucket(versionHistogramsBucketName).Put([]byte(histogram.Date), value)
+			},
+			lastBlock: func(blockNumber *big.Int) error {
+				bucket := tx.Bucket(stateBucketName)
+				if value := bucket.Get(lastBlockKey); value != nil && len(value) == 8 && utils.BytesToUint64(value) >= blockNumber.Uint64() {
+					return nil
+				}
+				return bucket.Put(lastBlockKey, utils.Uint64ToBytes(blockNumber.Uint64()))
+			},
+		})
+		if err != nil {
+			return err
+		}
+		return bumpRevision(tx)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// WriteSnapshot writes a consistent copy of the bolt file while the db stays online
+func (db *BoltDB) WriteSnapshot(w io.Writer) (int64, error) {
+	var n int64
+	err := db.db.View(func(tx *bolt.Tx) error {
+		var err error
+		n, err = tx.WriteTo(w)
+		return err
+	})
+	return n, err
+}
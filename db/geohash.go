@@ -0,0 +1,110 @@
+package db
+
+import "math"
+
+const geohashAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// geohashPrecision is the length of the indexed geohashes, about 5m cells
+const geohashPrecision = 9
+
+// maxGeohashCover is the maximum number of cells used to cover a box, larger boxes are covered with coarser cells
+const maxGeohashCover = 32
+
+// GeoBox is a latitude/longitude rectangle, boxes crossing the antimeridian must be split by the caller
+type GeoBox struct {
+	MinLatitude  float64
+	MinLongitude float64
+	MaxLatitude  float64
+	MaxLongitude float64
+}
+
+// Contains reports whether the point is in the box, edges included
+func (b GeoBox) Contains(latitude, longitude float64) bool {
+	return latitude >= b.MinLatitude && latitude <= b.MaxLatitude &&
+		longitude >= b.MinLongitude && longitude <= b.MaxLongitude
+}
+
+// Geohash encodes the point as a geohash of the given length
+func Geohash(latitude, longitude float64, precision int) string {
+	minLat, maxLat := -90.0, 90.0
+	minLon, maxLon := -180.0, 180.0
+	hash := make([]byte, precision)
+	even := true
+	for i := range hash {
+		var index int
+		for bit := 4; bit >= 0; bit-- {
+			if even {
+				mid := (minLon + maxLon) / 2
+				if longitude >= mid {
+					index |= 1 << bit
+					minLon = mid
+				} else {
+					maxLon = mid
+				}
+			} else {
+				mid := (minLat + maxLat) / 2
+				if latitude >= mid {
+					index |= 1 << bit
+					minLat = mid
+				} else {
+					maxLat = mid
+				}
+			}
+			even = !even
+		}
+		hash[i] = geohashAlphabet[index]
+	}
+	return string(hash)
+}
+
+// geohashCellSize returns the height and width in degrees of the cells of a precision
+func geohashCellSize(precision int) (float64, float64) {
+	bits := 5 * precision
+	lonBits := (bits + 1) / 2
+	latBits := bits / 2
+	return 180 / math.Exp2(float64(latBits)), 360 / math.Exp2(float64(lonBits))
+}
+
+// geohashCover returns the geohashes of the cells intersecting the box, at the finest precision
+// that needs at most maxGeohashCover cells
+func geohashCover(box GeoBox) []string {
+	precision := 1
+	for p := geohashPrecision; p > 1; p-- {
+		if len(geohashCells(box, p, maxGeohashCover)) <= maxGeohashCover {
+			precision = p
+			break
+		}
+	}
+	return geohashCells(box, precision, -1)
+}
+
+// geohashCells lists the cells of the precision intersecting the box, it stops after limit+1 cells unless limit is negative
+func geohashCells(box GeoBox, precision int, limit int) []string {
+	height, width := geohashCellSize(precision)
+	cellIndex := func(value, origin, size float64) int {
+		index := int(math.Floor((value - origin) / size))
+		max := int(math.Round(-2*origin/size)) - 1
+		if index > max {
+			index = max
+		}
+		if index < 0 {
+			index = 0
+		}
+		return index
+	}
+	minY, maxY := cellIndex(box.MinLatitude, -90, height), cellIndex(box.MaxLatitude, -90, height)
+	minX, maxX := cellIndex(box.MinLongitude, -180, width), cellIndex(box.MaxLongitude, -180, width)
+
+	var cells []string
+	for y := minY; y <= maxY; y++ {
+		for x := minX; x <= maxX; x++ {
+			if limit >= 0 && len(cells) > limit {
+				return cells
+			}
+			latitude := -90 + (float64(y)+0.5)*height
+			longitude := -180 + (float64(x)+0.5)*width
+			cells = append(cells, Geohash(latitude, longitude, precision))
+		}
+	}
+	return cells
+}
@@ -0,0 +1,101 @@
+package db
+
+import (
+	"bytes"
+	"encoding/json"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// nodesByGeohashBucketName indexes the located nodes by geohash, keys are the geohash followed by the operator id
+var nodesByGeohashBucketName = []byte("NodesByGeohash")
+
+func setupNodesByGeohashBucket(db *bolt.DB) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(nodesByGeohashBucketName)
+		return err
+	})
+}
+
+// geohashIndexKey returns the index key of the node location, nil if the node is not located
+func geohashIndexKey(geoData *GeoData, operatorID []byte) []byte {
+	if geoData.Latitude == 0 && geoData.Longitude == 0 {
+		return nil
+	}
+	hash := Geohash(geoData.Latitude, geoData.Longitude, geohashPrecision)
+	return append([]byte(hash), operatorID...)
+}
+
+// reindexNodeGeohash moves the index entry of a node from its previous location
+func reindexNodeGeohash(tx *bolt.Tx, operatorID []byte, previous *NodeData, geoData *GeoData) error {
+	index := tx.Bucket(nodesByGeohashBucketName)
+	key := geohashIndexKey(geoData, operatorID)
+	if previous != nil {
+		previousKey := geohashIndexKey(&previous.GeoData, operatorID)
+		if bytes.Equal(previousKey, key) {
+			return nil
+		}
+		if previousKey != nil {
+			if err := index.Delete(previousKey); err != nil {
+				return err
+			}
+		}
+	}
+	if key == nil {
+		return nil
+	}
+	return index.Put(key, nil)
+}
+
+func (db *BoltDB) ListNodesInBox(box GeoBox) ([]NodeData, error) {
+	var dataList []NodeData
+	err := db.db.View(func(tx *bolt.Tx) error {
+		nodes := tx.Bucket(nodeDataBucketName)
+		c := tx.Bucket(nodesByGeohashBucketName).Cursor()
+		for _, cell := range geohashCover(box) {
+			prefix := []byte(cell)
+			for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+				operatorID := k[geohashPrecision:]
+				value := nodes.Get(operatorID)
+				if value == nil {
+					continue
+				}
+				var data NodeData
+				if err := json.Unmarshal(value, &data); err != nil {
+					return err
+				}
+				// cells overlap the box edges
+				if !box.Contains(data.GeoData.Latitude, data.GeoData.Longitude) {
+					continue
+				}
+				data.OperatorID = string(operatorID)
+				var err error
+				data.ClaimedGeo, err = getLocationClaim(tx, operatorID)
+				if err != nil {
+					return err
+				}
+				dataList = append(dataList, data)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return dataList, nil
+}
+
+// migrateNodesByGeohash indexes the nodes stored before the geohash index existed
+func migrateNodesByGeohash(tx *bolt.Tx) error {
+	index := tx.Bucket(nodesByGeohashBucketName)
+	return tx.Bucket(nodeDataBucketName).ForEach(func(k, v []byte) error {
+		var data NodeData
+		if err := json.Unmarshal(v, &data); err != nil {
+			return err
+		}
+		if key := geohashIndexKey(&data.GeoData, k); key != nil {
+			return index.Put(key, nil)
+		}
+		return nil
+	})
+}
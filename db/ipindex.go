@@ -0,0 +1,109 @@
+package db
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// nodesByIPBucketName indexes the nodes by ip, keys are the 16 byte ip followed by the operator id,
+// so that the nodes of an ip or of a range are found with a cursor scan
+var nodesByIPBucketName = []byte("NodesByIP")
+
+func setupNodesByIPBucket(db *bolt.DB) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(nodesByIPBucketName)
+		return err
+	})
+}
+
+func ipIndexKey(ip net.IP, operatorID []byte) []byte {
+	return append(append([]byte{}, ip.To16()...), operatorID...)
+}
+
+// reindexNodeIP moves the index entry of a node from its previous ip
+func reindexNodeIP(tx *bolt.Tx, operatorID []byte, previous *NodeData, ipAddress string) error {
+	index := tx.Bucket(nodesByIPBucketName)
+	if previous != nil {
+		if previous.IPAddress == ipAddress {
+			return nil
+		}
+		if ip := net.ParseIP(previous.IPAddress); ip != nil {
+			if err := index.Delete(ipIndexKey(ip, operatorID)); err != nil {
+				return err
+			}
+		}
+	}
+	if ip := net.ParseIP(ipAddress); ip != nil {
+		return index.Put(ipIndexKey(ip, operatorID), nil)
+	}
+	return nil
+}
+
+// ipRange returns the first and last 16 byte ips of the network
+func ipRange(network *net.IPNet) (net.IP, net.IP) {
+	ip, mask := network.IP, network.Mask
+	if ip4 := ip.To4(); ip4 != nil && len(mask) == net.IPv4len {
+		ip = ip4
+	}
+	first := make(net.IP, len(ip))
+	last := make(net.IP, len(ip))
+	for i := range ip {
+		first[i] = ip[i] & mask[i]
+		last[i] = ip[i] | ^mask[i]
+	}
+	return first.To16(), last.To16()
+}
+
+func (db *BoltDB) ListNodesByIP(ip net.IP) ([]NodeData, error) {
+	return db.ListNodesInCIDR(&net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip.To16())*8, len(ip.To16())*8)})
+}
+
+func (db *BoltDB) ListNodesInCIDR(network *net.IPNet) ([]NodeData, error) {
+	first, last := ipRange(network)
+	var dataList []NodeData
+	err := db.db.View(func(tx *bolt.Tx) error {
+		nodes := tx.Bucket(nodeDataBucketName)
+		c := tx.Bucket(nodesByIPBucketName).Cursor()
+		for k, _ := c.Seek(first); k != nil && bytes.Compare(k[:net.IPv6len], last) <= 0; k, _ = c.Next() {
+			operatorID := k[net.IPv6len:]
+			value := nodes.Get(operatorID)
+			if value == nil {
+				continue
+			}
+			var data NodeData
+			if err := json.Unmarshal(value, &data); err != nil {
+				return err
+			}
+			data.OperatorID = string(operatorID)
+			var err error
+			data.ClaimedGeo, err = getLocationClaim(tx, operatorID)
+			if err != nil {
+				return err
+			}
+			dataList = append(dataList, data)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return dataList, nil
+}
+
+// migrateNodesByIP indexes the nodes stored before the ip index existed
+func migrateNodesByIP(tx *bolt.Tx) error {
+	index := tx.Bucket(nodesByIPBucketName)
+	return tx.Bucket(nodeDataBucketName).ForEach(func(k, v []byte) error {
+		var data NodeData
+		if err := json.Unmarshal(v, &data); err != nil {
+			return err
+		}
+		if ip := net.ParseIP(data.IPAddress); ip != nil {
+			return index.Put(ipIndexKey(ip, k), nil)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,153 @@
+package db
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net"
+
+	"github.com/stakestar/startracker/utils"
+	bolt "go.etcd.io/bbolt"
+)
+
+var schemaVersionKey = []byte("schemaVersion")
+
+// migration upgrades the stored data to Version, steps must be idempotent
+// since a step can be retried if the process stops before the version is saved
+type migration struct {
+	Version uint64
+	Name    string
+	Up      func(tx *bolt.Tx) error
+}
+
+// migrations are applied in order on open, new steps are appended with the next version
+var migrations = []migration{
+	{
+		Version: 1,
+		Name:    "store last block as uint64",
+		Up:      migrateLastBlockToUint64,
+	},
+	{
+		Version: 2,
+		Name:    "backfill node address family",
+		Up:      migrateNodeAddressFamily,
+	},
+	{
+		Version: 3,
+		Name:    "index nodes by ip",
+		Up:      migrateNodesByIP,
+	},
+	{
+		Version: 4,
+		Name:    "index nodes by geohash",
+		Up:      migrateNodesByGeohash,
+	},
+}
+
+// LatestSchemaVersion is the schema version written by this build
+func LatestSchemaVersion() uint64 {
+	return migrations[len(migrations)-1].Version
+}
+
+func (db *BoltDB) SchemaVersion() (uint64, error) {
+	var version uint64
+	err := db.db.View(func(tx *bolt.Tx) error {
+		version = getSchemaVersion(tx)
+		return nil
+	})
+	return version, err
+}
+
+func getSchemaVersion(tx *bolt.Tx) uint64 {
+	value := tx.Bucket(stateBucketName).Get(schemaVersionKey)
+	if len(value) != 8 {
+		return 0
+	}
+	return binary.LittleEndian.Uint64(value)
+}
+
+// migrate applies the pending migrations, each one in its own transaction with the new schema version
+func migrate(db *bolt.DB) error {
+	var current uint64
+	err := db.View(func(tx *bolt.Tx) error {
+		current = getSchemaVersion(tx)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if current > LatestSchemaVersion() {
+		return fmt.Errorf("database schema version %d is newer than the supported version %d", current, LatestSchemaVersion())
+	}
+
+	for _, m := range migrations {
+		if m.Version <= current {
+			continue
+		}
+		fmt.Printf("Migrating db to schema version %d: %s\n", m.Version, m.Name)
+		err := db.Update(func(tx *bolt.Tx) error {
+			if err := m.Up(tx); err != nil {
+				return err
+			}
+			return tx.Bucket(stateBucketName).Put(schemaVersionKey, utils.Uint64ToBytes(m.Version))
+		})
+		if err != nil {
+			return fmt.Errorf("migration %d (%s) failed: %w", m.Version, m.Name, err)
+		}
+	}
+	return nil
+}
+
+// migrateLastBlockToUint64 moves the last block from a decimal string under "lastBlock" to an uint64 under lastBlockKey
+func migrateLastBlockToUint64(tx *bolt.Tx) error {
+	bucket := tx.Bucket(stateBucketName)
+	legacyKey := []byte("lastBlock")
+	value := bucket.Get(legacyKey)
+	if value == nil {
+		return nil
+	}
+	blockNumber, ok := new(big.Int).SetString(string(value), 10)
+	if !ok || !blockNumber.IsUint64() {
+		return fmt.Errorf("error parsing last block number %q", value)
+	}
+	if err := bucket.Put(lastBlockKey, utils.Uint64ToBytes(blockNumber.Uint64())); err != nil {
+		return err
+	}
+	return bucket.Delete(legacyKey)
+}
+
+// migrateNodeAddressFamily sets the address family of nodes stored before it was recorded
+func migrateNodeAddressFamily(tx *bolt.Tx) error {
+	bucket := tx.Bucket(nodeDataBucketName)
+	updates := make(map[string][]byte)
+	err := bucket.ForEach(func(k, v []byte) error {
+		var data NodeData
+		if err := json.Unmarshal(v, &data); err != nil {
+			return err
+		}
+		ip := net.ParseIP(data.IPAddress)
+		if data.AddressFamily != "" || ip == nil {
+			return nil
+		}
+		data.AddressFamily = AddressFamilyIPv6
+		if ip.To4() != nil {
+			data.AddressFamily = AddressFamilyIPv4
+		}
+		value, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		updates[string(k)] = value
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for k, v := range updates {
+		if err := bucket.Put([]byte(k), v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
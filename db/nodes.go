@@ -37,19 +37,105 @@ func (db *BoltDB) StoreNodeData(data *NodeData) error {
 
 	data.UpdatedAt = time.Now()
 	key := []byte(data.OperatorID)
-	value, err := json.Marshal(data)
+	// claims are stored in their own bucket and joined on read
+	stored := *data
+	stored.ClaimedGeo = nil
+	stored.Outdated = false
+	value, err := json.Marshal(stored)
 	if err != nil {
 		return err
 	}
-	return db.db.Update(func(tx *bolt.Tx) error {
+	err = db.db.Update(func(tx *bolt.Tx) error {
+		if err := reindexNode(tx, key, data); err != nil {
+			return err
+		}
 		bucket := tx.Bucket(nodeDataBucketName)
-		return bucket.Put(key, value)
+		if err := bucket.Put(key, value); err != nil {
+			return err
+		}
+		return bumpRevision(tx)
 	})
+	if err != nil {
+		return err
+	}
+
+	for _, listener := range db.nodeDataListeners {
+		listener(saveData, data)
+	}
+	return nil
+}
+
+// reindexNode updates the secondary indexes of a node, it must be called before the node is replaced
+func reindexNode(tx *bolt.Tx, operatorID []byte, data *NodeData) error {
+	var previous *NodeData
+	if value := tx.Bucket(nodeDataBucketName).Get(operatorID); value != nil {
+		previous = &NodeData{}
+		if err := json.Unmarshal(value, previous); err != nil {
+			return err
+		}
+	}
+	if err := reindexNodeIP(tx, operatorID, previous, data.IPAddress); err != nil {
+		return err
+	}
+	return reindexNodeGeohash(tx, operatorID, previous, &data.GeoData)
+}
+
+// UpdateNodeGeoData replaces the geo data of a stored node without touching its last seen time
+func (db *BoltDB) UpdateNodeGeoData(operatorID string, geoData GeoData) error {
+	previous, err := db.GetNodeData(operatorID)
+	if err != nil {
+		return err
+	}
+	err = db.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(nodeDataBucketName)
+		key := []byte(operatorID)
+		value := bucket.Get(key)
+		if value == nil {
+			return ErrNotFound
+		}
+		var data NodeData
+		if err := json.Unmarshal(value, &data); err != nil {
+			return err
+		}
+		if err := reindexNodeGeohash(tx, key, &data, &geoData); err != nil {
+			return err
+		}
+		data.GeoData = geoData
+		value, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		if err := bucket.Put(key, value); err != nil {
+			return err
+		}
+		return bumpRevision(tx)
+	})
+	if err != nil {
+		return err
+	}
+
+	current := *previous
+	current.GeoData = geoData
+	for _, listener := range db.nodeDataListeners {
+		listener(previous, &current)
+	}
+	return nil
 }
 
 func (db *BoltDB) ListNodeData(onlyWithNotNilOperatorId bool) ([]NodeData, error) {
 	var dataList []NodeData
-	err := db.db.View(func(tx *bolt.Tx) error {
+	err := db.ForEachNodeData(onlyWithNotNilOperatorId, func(data *NodeData) error {
+		dataList = append(dataList, *data)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return dataList, nil
+}
+
+func (db *BoltDB) ForEachNodeData(onlyWithNotNilOperatorId bool, fn func(data *NodeData) error) error {
+	return db.db.View(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket(nodeDataBucketName)
 		c := bucket.Cursor()
 		for k, v := c.First(); k != nil; k, v = c.Next() {
@@ -58,11 +144,47 @@ func (db *BoltDB) ListNodeData(onlyWithNotNilOperatorId bool) ([]NodeData, error
 			if err != nil {
 				return err
 			}
+			// operator id is the key and is not part of the stored value
+			data.OperatorID = string(k)
+			data.ClaimedGeo, err = getLocationClaim(tx, k)
+			if err != nil {
+				return err
+			}
 
 			if onlyWithNotNilOperatorId && data.OperatorIDContract == 0 {
 				continue
 			}
 
+			if err := fn(&data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (db *BoltDB) ListNodeDataPage(onlyWithNotNilOperatorId bool, after string, limit int) ([]NodeData, error) {
+	var dataList []NodeData
+	err := db.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(nodeDataBucketName).Cursor()
+		k, v := c.Seek([]byte(after))
+		if k != nil && string(k) == after {
+			k, v = c.Next()
+		}
+		for ; k != nil && len(dataList) < limit; k, v = c.Next() {
+			var data NodeData
+			if err := json.Unmarshal(v, &data); err != nil {
+				return err
+			}
+			if onlyWithNotNilOperatorId && data.OperatorIDContract == 0 {
+				continue
+			}
+			data.OperatorID = string(k)
+			var err error
+			data.ClaimedGeo, err = getLocationClaim(tx, k)
+			if err != nil {
+				return err
+			}
 			dataList = append(dataList, data)
 		}
 		return nil
@@ -81,7 +203,13 @@ func (db *BoltDB) GetNodeData(operatorID string) (*NodeData, error) {
 		if value == nil {
 			return ErrNotFound
 		}
-		return json.Unmarshal(value, &data)
+		data.OperatorID = operatorID
+		if err := json.Unmarshal(value, &data); err != nil {
+			return err
+		}
+		var err error
+		data.ClaimedGeo, err = getLocationClaim(tx, []byte(operatorID))
+		return err
 	})
 	if err != nil {
 		return nil, err
@@ -109,7 +237,12 @@ func (db *BoltDB) GetNodeByOperatorContractId(operatorContractId string) (*NodeD
 		if value == nil {
 			return ErrNotFound
 		}
-		return json.Unmarshal(value, &data)
+		data.OperatorID = string(operatorID)
+		if err := json.Unmarshal(value, &data); err != nil {
+			return err
+		}
+		data.ClaimedGeo, err = getLocationClaim(tx, operatorID)
+		return err
 	})
 	if err != nil {
 		return nil, err
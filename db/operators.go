@@ -22,18 +22,28 @@ func setupOperatorsBucket(db *bolt.DB) error {
 }
 
 func (db *BoltDB) SaveOperatorAndUpdateNodeData(operator *Operator) error {
-	err := db.SaveOperator(operator)
+	value, err := json.Marshal(operator)
 	if err != nil {
 		return err
 	}
-
-	err = db.SaveOperatorContractIdToOperatorId(operator.OperatorID, operator.OperatorIDContract)
+	// the operator and its contract id are written together so that a revision never has only one of them
+	err = db.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(operatorsBucketName).Put([]byte(operator.OperatorID), value); err != nil {
+			return err
+		}
+		bucket := tx.Bucket(operatorsContractIdToOperatorIdBucketName)
+		if err := bucket.Put(utils.Uint64ToBytes(operator.OperatorIDContract), []byte(operator.OperatorID)); err != nil {
+			return err
+		}
+		return bumpRevision(tx)
+	})
 	if err != nil {
 		return err
 	}
 
 	nodeData, err := db.GetNodeData(operator.OperatorID)
-	if err == nil {
+	// the node is only updated when the id changes so that saving an operator again is idempotent
+	if err == nil && nodeData.OperatorIDContract != operator.OperatorIDContract {
 		nodeData.OperatorIDContract = operator.OperatorIDContract
 		err = db.StoreNodeData(nodeData)
 		if err != nil {
@@ -44,27 +54,6 @@ func (db *BoltDB) SaveOperatorAndUpdateNodeData(operator *Operator) error {
 	return nil
 }
 
-func (db *BoltDB) SaveOperator(operator *Operator) error {
-	key := []byte(operator.OperatorID)
-	value, err := json.Marshal(operator)
-	if err != nil {
-		return err
-	}
-	return db.db.Update(func(tx *bolt.Tx) error {
-		bucket := tx.Bucket(operatorsBucketName)
-		return bucket.Put(key, value)
-	})
-}
-
-func (db *BoltDB) SaveOperatorContractIdToOperatorId(operatorId string, operatorIdContract uint64) error {
-	key := utils.Uint64ToBytes(operatorIdContract)
-	value := []byte(operatorId)
-	return db.db.Update(func(tx *bolt.Tx) error {
-		bucket := tx.Bucket(operatorsContractIdToOperatorIdBucketName)
-		return bucket.Put(key, value)
-	})
-}
-
 func (db *BoltDB) GetOperatorByOperatorId(operatorId string) (*Operator, error) {
 	var data Operator
 	err := db.db.View(func(tx *bolt.Tx) error {
@@ -101,3 +90,22 @@ func (db *BoltDB) GetOperatorByOperatorIdContract(operatorIdContract uint64) (*O
 	}
 	return &data, nil
 }
+
+func (db *BoltDB) ListOperators() ([]Operator, error) {
+	var operators []Operator
+	err := db.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(operatorsBucketName)
+		return bucket.ForEach(func(k, v []byte) error {
+			var operator Operator
+			if err := json.Unmarshal(v, &operator); err != nil {
+				return err
+			}
+			operators = append(operators, operator)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return operators, nil
+}
@@ -0,0 +1,39 @@
+package db
+
+import (
+	"encoding/binary"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var revisionKey = []byte("revision")
+
+// bumpRevision increments the data revision, it must be called in the transaction of every write to served data
+func bumpRevision(tx *bolt.Tx) error {
+	revision := getRevision(tx)
+	value := make([]byte, 16)
+	binary.LittleEndian.PutUint64(value[:8], revision.Number+1)
+	binary.LittleEndian.PutUint64(value[8:], uint64(time.Now().UnixNano()))
+	return tx.Bucket(stateBucketName).Put(revisionKey, value)
+}
+
+func getRevision(tx *bolt.Tx) Revision {
+	value := tx.Bucket(stateBucketName).Get(revisionKey)
+	if len(value) != 16 {
+		return Revision{}
+	}
+	return Revision{
+		Number:    binary.LittleEndian.Uint64(value[:8]),
+		UpdatedAt: time.Unix(0, int64(binary.LittleEndian.Uint64(value[8:]))).UTC(),
+	}
+}
+
+func (db *BoltDB) Revision() (Revision, error) {
+	var revision Revision
+	err := db.db.View(func(tx *bolt.Tx) error {
+		revision = getRevision(tx)
+		return nil
+	})
+	return revision, err
+}
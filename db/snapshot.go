@@ -0,0 +1,223 @@
+package db
+
+import (
+	"context"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// SnapshotStore serves reads from a bolt snapshot file and reopens it when the file is replaced,
+// snapshots must be replaced atomically (written next to the path and renamed)
+type SnapshotStore struct {
+	path string
+
+	mu      sync.RWMutex
+	current *BoltDB
+}
+
+func NewSnapshotStore(path string) (*SnapshotStore, error) {
+	current, err := NewBoltDBReadOnly(path)
+	if err != nil {
+		return nil, err
+	}
+	return &SnapshotStore{
+		path:    path,
+		current: current,
+	}, nil
+}
+
+// Watch reopens the snapshot when its modification time changes until the context is done
+func (s *SnapshotStore) Watch(ctx context.Context, interval time.Duration, logger *zap.Logger) {
+	var modTime time.Time
+	if info, err := os.Stat(s.path); err == nil {
+		modTime = info.ModTime()
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			info, err := os.Stat(s.path)
+			if err != nil {
+				logger.Warn("could not stat db snapshot", zap.String("path", s.path), zap.Error(err))
+				continue
+			}
+			if info.ModTime().Equal(modTime) {
+				continue
+			}
+			if err := s.Reload(); err != nil {
+				logger.Error("could not reload db snapshot", zap.String("path", s.path), zap.Error(err))
+				continue
+			}
+			modTime = info.ModTime()
+			logger.Info("reloaded db snapshot", zap.String("path", s.path), zap.Time("modTime", modTime))
+		}
+	}
+}
+
+// Reload opens the snapshot file again, reads hold the lock so the previous file is closed once they are done
+func (s *SnapshotStore) Reload() error {
+	next, err := NewBoltDBReadOnly(s.path)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	previous := s.current
+	s.current = next
+	s.mu.Unlock()
+	return previous.Close()
+}
+
+func (s *SnapshotStore) Close() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.current.Close()
+}
+
+// AddNodeDataListener is a no-op, snapshots are never written
+func (s *SnapshotStore) AddNodeDataListener(NodeDataListener) {}
+
+func (s *SnapshotStore) StoreNodeData(*NodeData) error {
+	return ErrReadOnly
+}
+
+func (s *SnapshotStore) UpdateNodeGeoData(string, GeoData) error {
+	return ErrReadOnly
+}
+
+func (s *SnapshotStore) ListNodesByIP(ip net.IP) ([]NodeData, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.current.ListNodesByIP(ip)
+}
+
+func (s *SnapshotStore) ListNodesInCIDR(network *net.IPNet) ([]NodeData, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.current.ListNodesInCIDR(network)
+}
+
+func (s *SnapshotStore) ListNodesInBox(box GeoBox) ([]NodeData, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.current.ListNodesInBox(box)
+}
+
+func (s *SnapshotStore) ListNodeData(onlyWithNotNilOperatorId bool) ([]NodeData, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.current.ListNodeData(onlyWithNotNilOperatorId)
+}
+
+func (s *SnapshotStore) ForEachNodeData(onlyWithNotNilOperatorId bool, fn func(data *NodeData) error) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.current.ForEachNodeData(onlyWithNotNilOperatorId, fn)
+}
+
+func (s *SnapshotStore) ListNodeDataPage(onlyWithNotNilOperatorId bool, after string, limit int) ([]NodeData, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.current.ListNodeDataPage(onlyWithNotNilOperatorId, after, limit)
+}
+
+func (s *SnapshotStore) GetNodeData(operatorID string) (*NodeData, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.current.GetNodeData(operatorID)
+}
+
+func (s *SnapshotStore) GetNodeByOperatorContractId(operatorContractId string) (*NodeData, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.current.GetNodeByOperatorContractId(operatorContractId)
+}
+
+func (s *SnapshotStore) SaveOperatorAndUpdateNodeData(*Operator) error {
+	return ErrReadOnly
+}
+
+func (s *SnapshotStore) GetOperatorByOperatorId(operatorId string) (*Operator, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.current.GetOperatorByOperatorId(operatorId)
+}
+
+func (s *SnapshotStore) GetOperatorByOperatorIdContract(operatorIdContract uint64) (*Operator, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.current.GetOperatorByOperatorIdContract(operatorIdContract)
+}
+
+func (s *SnapshotStore) ListOperators() ([]Operator, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.current.ListOperators()
+}
+
+func (s *SnapshotStore) SaveLocationClaim(string, *LocationClaim) error {
+	return ErrReadOnly
+}
+
+func (s *SnapshotStore) GetLocationClaim(operatorID string) (*LocationClaim, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.current.GetLocationClaim(operatorID)
+}
+
+func (s *SnapshotStore) SaveVersionHistogram(*VersionHistogram) error {
+	return ErrReadOnly
+}
+
+func (s *SnapshotStore) ListVersionHistograms() ([]VersionHistogram, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.current.ListVersionHistograms()
+}
+
+func (s *SnapshotStore) Revision() (Revision, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.current.Revision()
+}
+
+func (s *SnapshotStore) GetLastBlock() (*big.Int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.current.GetLastBlock()
+}
+
+func (s *SnapshotStore) SaveLastBlock(*big.Int) error {
+	return ErrReadOnly
+}
+
+func (s *SnapshotStore) SchemaVersion() (uint64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.current.SchemaVersion()
+}
+
+func (s *SnapshotStore) Export(w io.Writer) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.current.Export(w)
+}
+
+func (s *SnapshotStore) Import(io.Reader) (ImportStats, error) {
+	return nil, ErrReadOnly
+}
+
+func (s *SnapshotStore) WriteSnapshot(w io.Writer) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.current.WriteSnapshot(w)
+}
@@ -0,0 +1,182 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// SQLDB stores the tracker data in SQLite or PostgreSQL,
+// entities are kept as json documents next to the columns used for lookups and analytic queries
+type SQLDB struct {
+	db     *sql.DB
+	driver string
+
+	nodeDataListeners []NodeDataListener
+}
+
+// sqlMigration upgrades the schema by one version inside the transaction of the step
+type sqlMigration func(tx *sql.Tx) error
+
+func statement(query string) sqlMigration {
+	return func(tx *sql.Tx) error {
+		_, err := tx.Exec(query)
+		return err
+	}
+}
+
+// sqlMigrations are applied in order on open, the schema version is the number of applied steps
+var sqlMigrations = []sqlMigration{
+	statement(`CREATE TABLE IF NOT EXISTS state (
+		key TEXT PRIMARY KEY,
+		value TEXT NOT NULL
+	)`),
+	statement(`CREATE TABLE IF NOT EXISTS nodes (
+		operator_id TEXT PRIMARY KEY,
+		operator_id_contract BIGINT NOT NULL DEFAULT 0,
+		updated_at TIMESTAMP NOT NULL,
+		ip_address TEXT NOT NULL DEFAULT '',
+		node_version TEXT NOT NULL DEFAULT '',
+		country_code TEXT NOT NULL DEFAULT '',
+		city TEXT NOT NULL DEFAULT '',
+		latitude DOUBLE PRECISION NOT NULL DEFAULT 0,
+		longitude DOUBLE PRECISION NOT NULL DEFAULT 0,
+		asn BIGINT NOT NULL DEFAULT 0,
+		data TEXT NOT NULL
+	)`),
+	statement(`CREATE INDEX IF NOT EXISTS nodes_operator_id_contract ON nodes (operator_id_contract)`),
+	statement(`CREATE TABLE IF NOT EXISTS operators (
+		operator_id TEXT PRIMARY KEY,
+		operator_id_contract BIGINT NOT NULL UNIQUE,
+		data TEXT NOT NULL
+	)`),
+	statement(`CREATE TABLE IF NOT EXISTS location_claims (
+		operator_id TEXT PRIMARY KEY,
+		data TEXT NOT NULL
+	)`),
+	statement(`CREATE TABLE IF NOT EXISTS version_histograms (
+		date TEXT PRIMARY KEY,
+		data TEXT NOT NULL
+	)`),
+	statement(`ALTER TABLE nodes ADD COLUMN ip_key TEXT NOT NULL DEFAULT ''`),
+	statement(`CREATE INDEX IF NOT EXISTS nodes_ip_key ON nodes (ip_key)`),
+	backfillNodeIPKeys,
+	statement(`CREATE INDEX IF NOT EXISTS nodes_location ON nodes (latitude, longitude)`),
+}
+
+const sqlSchemaVersionKey = "schemaVersion"
+
+func NewSQLDB(driver, dsn string) (*SQLDB, error) {
+	driverName := driver
+	if driver == DriverSQLite {
+		driverName = "sqlite3"
+	}
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+	if driver == DriverSQLite {
+		// sqlite has a single writer, serialize access to avoid busy errors
+		db.SetMaxOpenConns(1)
+	}
+	if err := db.Ping(); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	s := &SQLDB{
+		db:     db,
+		driver: driver,
+	}
+	if err := s.migrate(); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *SQLDB) Close() error {
+	return s.db.Close()
+}
+
+func (s *SQLDB) AddNodeDataListener(listener NodeDataListener) {
+	s.nodeDataListeners = append(s.nodeDataListeners, listener)
+}
+
+func (s *SQLDB) migrate() error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := sqlMigrations[0](tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	current, err := s.SchemaVersion()
+	if err != nil {
+		return err
+	}
+	if current > uint64(len(sqlMigrations)) {
+		return fmt.Errorf("database schema version %d is newer than the supported version %d", current, len(sqlMigrations))
+	}
+
+	for version := current + 1; version <= uint64(len(sqlMigrations)); version++ {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return err
+		}
+		if err := sqlMigrations[version-1](tx); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("migration %d failed: %w", version, err)
+		}
+		if err := putState(tx, sqlSchemaVersionKey, fmt.Sprint(version)); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// write runs a write in a transaction that also bumps the data revision
+func (s *SQLDB) write(fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if err := bumpSQLRevision(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// execer is implemented by both *sql.DB and *sql.Tx
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+func putState(e execer, key, value string) error {
+	_, err := e.Exec(`INSERT INTO state (key, value) VALUES ($1, $2)
+		ON CONFLICT (key) DO UPDATE SET value = excluded.value`, key, value)
+	return err
+}
+
+func (s *SQLDB) getState(key string) (string, error) {
+	var value string
+	err := s.db.QueryRow(`SELECT value FROM state WHERE key = $1`, key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return "", ErrNotFound
+	}
+	return value, err
+}
@@ -0,0 +1,112 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"math/big"
+)
+
+// Export writes all data as json lines from a single read transaction
+func (s *SQLDB) Export(w io.Writer) error {
+	e, err := newExportWriter(w)
+	if err != nil {
+		return err
+	}
+	tx, err := s.db.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	tables := []struct {
+		query      string
+		recordType string
+		keyed      bool
+	}{
+		{`SELECT operator_id, data FROM operators ORDER BY operator_id`, RecordOperator, false},
+		{`SELECT operator_id, data FROM nodes ORDER BY operator_id`, RecordNode, true},
+		{`SELECT operator_id, data FROM location_claims ORDER BY operator_id`, RecordLocationClaim, true},
+		{`SELECT date, data FROM version_histograms ORDER BY date`, RecordVersionHistogram, false},
+	}
+	for _, t := range tables {
+		if err := exportRows(tx, t.query, t.recordType, t.keyed, e); err != nil {
+			return err
+		}
+	}
+
+	var lastBlock string
+	err = tx.QueryRow(`SELECT value FROM state WHERE key = $1`, sqlLastBlockKey).Scan(&lastBlock)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if err == nil {
+		if err := e.writeValue(RecordLastBlock, "", lastBlock); err != nil {
+			return err
+		}
+	}
+	return e.flush()
+}
+
+func exportRows(tx *sql.Tx, query, recordType string, keyed bool, e *exportWriter) error {
+	rows, err := tx.Query(query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return err
+		}
+		if !keyed {
+			key = ""
+		}
+		if err := e.write(recordType, key, []byte(value)); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// Import upserts the records of an export in a single transaction,
+// node data keeps its update time, listeners are not called and the last block only moves forward
+func (s *SQLDB) Import(r io.Reader) (ImportStats, error) {
+	lastBlock, err := s.GetLastBlock()
+	if err != nil {
+		return nil, err
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	stats, err := readExport(r, &importHandler{
+		node: func(data *NodeData) error {
+			return putNode(tx, data)
+		},
+		operator: func(operator *Operator) error {
+			return putOperator(tx, operator)
+		},
+		locationClaim: func(operatorID string, claim *LocationClaim) error {
+			return putLocationClaim(tx, operatorID, claim)
+		},
+		versionHistogram: func(histogram *VersionHistogram) error {
+			return putVersionHistogram(tx, histogram)
+		},
+		lastBlock: func(blockNumber *big.Int) error {
+			if lastBlock.Cmp(blockNumber) >= 0 {
+				return nil
+			}
+			return putState(tx, sqlLastBlockKey, blockNumber.String())
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := bumpSQLRevision(tx); err != nil {
+		return nil, err
+	}
+	return stats, tx.Commit()
+}
@@ -0,0 +1,256 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"net"
+	"time"
+
+	"github.com/stakestar/startracker/utils"
+)
+
+func (s *SQLDB) StoreNodeData(data *NodeData) error {
+	saveData, err := s.GetNodeData(data.OperatorID)
+	if err != nil && err != ErrNotFound {
+		return err
+	}
+
+	if saveData != nil && saveData.OperatorIDContract != 0 {
+		data.OperatorIDContract = saveData.OperatorIDContract
+	} else {
+		operator, err := s.GetOperatorByOperatorId(data.OperatorID)
+		if err != nil && err != ErrNotFound {
+			return err
+		}
+		if operator != nil {
+			data.OperatorIDContract = operator.OperatorIDContract
+		}
+	}
+
+	data.UpdatedAt = time.Now()
+	if err := s.putNodeData(data); err != nil {
+		return err
+	}
+
+	for _, listener := range s.nodeDataListeners {
+		listener(saveData, data)
+	}
+	return nil
+}
+
+func (s *SQLDB) putNodeData(data *NodeData) error {
+	return s.write(func(tx *sql.Tx) error {
+		return putNode(tx, data)
+	})
+}
+
+func putNode(e execer, data *NodeData) error {
+	// claims are stored in their own table and joined on read
+	stored := *data
+	stored.ClaimedGeo = nil
+	stored.Outdated = false
+	value, err := json.Marshal(stored)
+	if err != nil {
+		return err
+	}
+	_, err = e.Exec(`INSERT INTO nodes (operator_id, operator_id_contract, updated_at, ip_address, node_version, country_code, city, latitude, longitude, asn, data, ip_key)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		ON CONFLICT (operator_id) DO UPDATE SET
+			operator_id_contract = excluded.operator_id_contract,
+			updated_at = excluded.updated_at,
+			ip_address = excluded.ip_address,
+			node_version = excluded.node_version,
+			country_code = excluded.country_code,
+			city = excluded.city,
+			latitude = excluded.latitude,
+			longitude = excluded.longitude,
+			asn = excluded.asn,
+			data = excluded.data,
+			ip_key = excluded.ip_key`,
+		data.OperatorID,
+		int64(data.OperatorIDContract),
+		data.UpdatedAt.UTC(),
+		data.IPAddress,
+		data.NodeVersion,
+		data.GeoData.CountryCode,
+		data.GeoData.City,
+		data.GeoData.Latitude,
+		data.GeoData.Longitude,
+		int64(data.GeoData.ASN),
+		string(value),
+		sqlIPKey(net.ParseIP(data.IPAddress)),
+	)
+	return err
+}
+
+func (s *SQLDB) UpdateNodeGeoData(operatorID string, geoData GeoData) error {
+	previous, err := s.GetNodeData(operatorID)
+	if err != nil {
+		return err
+	}
+	current := *previous
+	current.GeoData = geoData
+	if err := s.putNodeData(&current); err != nil {
+		return err
+	}
+
+	for _, listener := range s.nodeDataListeners {
+		listener(previous, &current)
+	}
+	return nil
+}
+
+const selectNodes = `SELECT n.operator_id, n.data, COALESCE(c.data, '') FROM nodes n
+	LEFT JOIN location_claims c ON c.operator_id = n.operator_id`
+
+func (s *SQLDB) ListNodeData(onlyWithNotNilOperatorId bool) ([]NodeData, error) {
+	return s.queryNodes(listNodesQuery(onlyWithNotNilOperatorId))
+}
+
+func (s *SQLDB) ForEachNodeData(onlyWithNotNilOperatorId bool, fn func(data *NodeData) error) error {
+	rows, err := s.db.Query(listNodesQuery(onlyWithNotNilOperatorId))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		data, err := scanNodeData(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(data); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (s *SQLDB) ListNodeDataPage(onlyWithNotNilOperatorId bool, after string, limit int) ([]NodeData, error) {
+	query := selectNodes + ` WHERE n.operator_id > $1`
+	if onlyWithNotNilOperatorId {
+		query += ` AND n.operator_id_contract != 0`
+	}
+	return s.queryNodes(query+` ORDER BY n.operator_id LIMIT $2`, after, limit)
+}
+
+func listNodesQuery(onlyWithNotNilOperatorId bool) string {
+	query := selectNodes
+	if onlyWithNotNilOperatorId {
+		query += ` WHERE n.operator_id_contract != 0`
+	}
+	return query + ` ORDER BY n.operator_id`
+}
+
+func (s *SQLDB) ListNodesByIP(ip net.IP) ([]NodeData, error) {
+	return s.queryNodes(selectNodes+` WHERE n.ip_key = $1 ORDER BY n.operator_id`, sqlIPKey(ip))
+}
+
+func (s *SQLDB) ListNodesInCIDR(network *net.IPNet) ([]NodeData, error) {
+	first, last := ipRange(network)
+	return s.queryNodes(selectNodes+` WHERE n.ip_key BETWEEN $1 AND $2 ORDER BY n.ip_key, n.operator_id`, sqlIPKey(first), sqlIPKey(last))
+}
+
+func (s *SQLDB) ListNodesInBox(box GeoBox) ([]NodeData, error) {
+	return s.queryNodes(selectNodes+` WHERE n.latitude BETWEEN $1 AND $2 AND n.longitude BETWEEN $3 AND $4
+		AND NOT (n.latitude = 0 AND n.longitude = 0) ORDER BY n.operator_id`,
+		box.MinLatitude, box.MaxLatitude, box.MinLongitude, box.MaxLongitude)
+}
+
+func (s *SQLDB) queryNodes(query string, args ...interface{}) ([]NodeData, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var dataList []NodeData
+	for rows.Next() {
+		data, err := scanNodeData(rows)
+		if err != nil {
+			return nil, err
+		}
+		dataList = append(dataList, *data)
+	}
+	return dataList, rows.Err()
+}
+
+func (s *SQLDB) GetNodeData(operatorID string) (*NodeData, error) {
+	return scanNodeData(s.db.QueryRow(selectNodes+` WHERE n.operator_id = $1`, operatorID))
+}
+
+func (s *SQLDB) GetNodeByOperatorContractId(operatorContractId string) (*NodeData, error) {
+	id, err := utils.StringToUint64(operatorContractId)
+	if err != nil {
+		return nil, err
+	}
+	operator, err := s.GetOperatorByOperatorIdContract(id)
+	if err != nil {
+		return nil, err
+	}
+	return s.GetNodeData(operator.OperatorID)
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanNodeData(row scanner) (*NodeData, error) {
+	var operatorID, value, claim string
+	if err := row.Scan(&operatorID, &value, &claim); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	var data NodeData
+	if err := json.Unmarshal([]byte(value), &data); err != nil {
+		return nil, err
+	}
+	data.OperatorID = operatorID
+	if claim != "" {
+		data.ClaimedGeo = &LocationClaim{}
+		if err := json.Unmarshal([]byte(claim), data.ClaimedGeo); err != nil {
+			return nil, err
+		}
+	}
+	return &data, nil
+}
+
+// sqlIPKey is the hex encoded 16 byte ip, ordered like the addresses so that ranges are found with the ip_key index
+func sqlIPKey(ip net.IP) string {
+	if ip == nil {
+		return ""
+	}
+	return hex.EncodeToString(ip.To16())
+}
+
+// backfillNodeIPKeys sets the ip key of nodes stored before the column existed
+func backfillNodeIPKeys(tx *sql.Tx) error {
+	rows, err := tx.Query(`SELECT operator_id, ip_address FROM nodes`)
+	if err != nil {
+		return err
+	}
+	keys := make(map[string]string)
+	for rows.Next() {
+		var operatorID, ipAddress string
+		if err := rows.Scan(&operatorID, &ipAddress); err != nil {
+			_ = rows.Close()
+			return err
+		}
+		keys[operatorID] = sqlIPKey(net.ParseIP(ipAddress))
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for operatorID, key := range keys {
+		if _, err := tx.Exec(`UPDATE nodes SET ip_key = $1 WHERE operator_id = $2`, key, operatorID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
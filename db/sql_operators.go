@@ -0,0 +1,81 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+)
+
+func (s *SQLDB) SaveOperatorAndUpdateNodeData(operator *Operator) error {
+	err := s.write(func(tx *sql.Tx) error {
+		return putOperator(tx, operator)
+	})
+	if err != nil {
+		return err
+	}
+
+	nodeData, err := s.GetNodeData(operator.OperatorID)
+	// the node is only updated when the id changes so that saving an operator again is idempotent
+	if err == nil && nodeData.OperatorIDContract != operator.OperatorIDContract {
+		nodeData.OperatorIDContract = operator.OperatorIDContract
+		err = s.StoreNodeData(nodeData)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *SQLDB) GetOperatorByOperatorId(operatorId string) (*Operator, error) {
+	return scanOperator(s.db.QueryRow(`SELECT data FROM operators WHERE operator_id = $1`, operatorId))
+}
+
+func (s *SQLDB) GetOperatorByOperatorIdContract(operatorIdContract uint64) (*Operator, error) {
+	return scanOperator(s.db.QueryRow(`SELECT data FROM operators WHERE operator_id_contract = $1`, int64(operatorIdContract)))
+}
+
+func (s *SQLDB) ListOperators() ([]Operator, error) {
+	rows, err := s.db.Query(`SELECT data FROM operators ORDER BY operator_id_contract`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var operators []Operator
+	for rows.Next() {
+		operator, err := scanOperator(rows)
+		if err != nil {
+			return nil, err
+		}
+		operators = append(operators, *operator)
+	}
+	return operators, rows.Err()
+}
+
+func scanOperator(row scanner) (*Operator, error) {
+	var value string
+	if err := row.Scan(&value); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	var operator Operator
+	if err := json.Unmarshal([]byte(value), &operator); err != nil {
+		return nil, err
+	}
+	return &operator, nil
+}
+
+func putOperator(e execer, operator *Operator) error {
+	value, err := json.Marshal(operator)
+	if err != nil {
+		return err
+	}
+	_, err = e.Exec(`INSERT INTO operators (operator_id, operator_id_contract, data) VALUES ($1, $2, $3)
+		ON CONFLICT (operator_id) DO UPDATE SET
+			operator_id_contract = excluded.operator_id_contract,
+			data = excluded.data`,
+		operator.OperatorID, int64(operator.OperatorIDContract), string(value))
+	return err
+}
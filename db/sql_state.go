@@ -0,0 +1,151 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strconv"
+	"time"
+)
+
+const (
+	sqlLastBlockKey         = "lastBlockNumber"
+	sqlRevisionKey          = "revision"
+	sqlRevisionUpdatedAtKey = "revisionUpdatedAt"
+)
+
+func (s *SQLDB) GetLastBlock() (*big.Int, error) {
+	value, err := s.getState(sqlLastBlockKey)
+	if err == ErrNotFound {
+		return new(big.Int), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	blockNumber, ok := new(big.Int).SetString(value, 10)
+	if !ok {
+		return nil, errors.New("error parsing last block number")
+	}
+	return blockNumber, nil
+}
+
+func (s *SQLDB) SaveLastBlock(blockNumber *big.Int) error {
+	return putState(s.db, sqlLastBlockKey, blockNumber.String())
+}
+
+// bumpSQLRevision increments the data revision in the statement itself so that concurrent writers never share one
+func bumpSQLRevision(tx *sql.Tx) error {
+	_, err := tx.Exec(`INSERT INTO state (key, value) VALUES ($1, '1')
+		ON CONFLICT (key) DO UPDATE SET value = CAST(CAST(state.value AS BIGINT) + 1 AS TEXT)`, sqlRevisionKey)
+	if err != nil {
+		return err
+	}
+	return putState(tx, sqlRevisionUpdatedAtKey, time.Now().UTC().Format(time.RFC3339Nano))
+}
+
+func (s *SQLDB) Revision() (Revision, error) {
+	var revision Revision
+	rows, err := s.db.Query(`SELECT key, value FROM state WHERE key IN ($1, $2)`, sqlRevisionKey, sqlRevisionUpdatedAtKey)
+	if err != nil {
+		return revision, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return revision, err
+		}
+		switch key {
+		case sqlRevisionKey:
+			revision.Number, err = strconv.ParseUint(value, 10, 64)
+		case sqlRevisionUpdatedAtKey:
+			revision.UpdatedAt, err = time.Parse(time.RFC3339Nano, value)
+		}
+		if err != nil {
+			return revision, err
+		}
+	}
+	return revision, rows.Err()
+}
+
+func (s *SQLDB) SchemaVersion() (uint64, error) {
+	value, err := s.getState(sqlSchemaVersionKey)
+	if err == ErrNotFound {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(value, 10, 64)
+}
+
+func (s *SQLDB) SaveLocationClaim(operatorID string, claim *LocationClaim) error {
+	return s.write(func(tx *sql.Tx) error {
+		return putLocationClaim(tx, operatorID, claim)
+	})
+}
+
+func putLocationClaim(e execer, operatorID string, claim *LocationClaim) error {
+	value, err := json.Marshal(claim)
+	if err != nil {
+		return err
+	}
+	_, err = e.Exec(`INSERT INTO location_claims (operator_id, data) VALUES ($1, $2)
+		ON CONFLICT (operator_id) DO UPDATE SET data = excluded.data`, operatorID, string(value))
+	return err
+}
+
+func (s *SQLDB) GetLocationClaim(operatorID string) (*LocationClaim, error) {
+	var value string
+	err := s.db.QueryRow(`SELECT data FROM location_claims WHERE operator_id = $1`, operatorID).Scan(&value)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var claim LocationClaim
+	if err := json.Unmarshal([]byte(value), &claim); err != nil {
+		return nil, err
+	}
+	return &claim, nil
+}
+
+func (s *SQLDB) SaveVersionHistogram(histogram *VersionHistogram) error {
+	return s.write(func(tx *sql.Tx) error {
+		return putVersionHistogram(tx, histogram)
+	})
+}
+
+func putVersionHistogram(e execer, histogram *VersionHistogram) error {
+	value, err := json.Marshal(histogram)
+	if err != nil {
+		return err
+	}
+	_, err = e.Exec(`INSERT INTO version_histograms (date, data) VALUES ($1, $2)
+		ON CONFLICT (date) DO UPDATE SET data = excluded.data`, histogram.Date, string(value))
+	return err
+}
+
+func (s *SQLDB) ListVersionHistograms() ([]VersionHistogram, error) {
+	rows, err := s.db.Query(`SELECT data FROM version_histograms ORDER BY date`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var histograms []VersionHistogram
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
+		}
+		var histogram VersionHistogram
+		if err := json.Unmarshal([]byte(value), &histogram); err != nil {
+			return nil, err
+		}
+		histograms = append(histograms, histogram)
+	}
+	return histograms, rows.Err()
+}
@@ -1,14 +1,17 @@
 package db
 
 import (
-	"errors"
+	"encoding/binary"
 	"math/big"
 
+	"github.com/stakestar/startracker/utils"
 	bolt "go.etcd.io/bbolt"
 )
 
 var stateBucketName = []byte("State")
 
+var lastBlockKey = []byte("lastBlockNumber")
+
 func setupStateBucket(db *bolt.DB) error {
 	return db.Update(func(tx *bolt.Tx) error {
 		_, err := tx.CreateBucketIfNotExists(stateBucketName)
@@ -20,15 +23,11 @@ func (db *BoltDB) GetLastBlock() (*big.Int, error) {
 	var data = new(big.Int)
 	err := db.db.View(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket(stateBucketName)
-		value := bucket.Get([]byte("lastBlock"))
-		if value == nil {
+		value := bucket.Get(lastBlockKey)
+		if len(value) != 8 {
 			return nil
 		}
-		var ok bool
-		data, ok = data.SetString(string(value), 10)
-		if !ok {
-			return errors.New("error parsing last block number")
-		}
+		data.SetUint64(binary.LittleEndian.Uint64(value))
 		return nil
 	})
 	if err != nil {
@@ -38,9 +37,8 @@ func (db *BoltDB) GetLastBlock() (*big.Int, error) {
 }
 
 func (db *BoltDB) SaveLastBlock(blockNumber *big.Int) error {
-	key := []byte("lastBlock")
 	return db.db.Update(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket(stateBucketName)
-		return bucket.Put(key, []byte(blockNumber.String()))
+		return bucket.Put(lastBlockKey, utils.Uint64ToBytes(blockNumber.Uint64()))
 	})
 }
@@ -0,0 +1,81 @@
+package db
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+)
+
+// database drivers
+const (
+	DriverBolt     = "bolt"
+	DriverSQLite   = "sqlite"
+	DriverPostgres = "postgres"
+)
+
+// Store is the storage of nodes, operators and tracker state, implemented by BoltDB and SQLDB
+type Store interface {
+	io.Closer
+
+	// AddNodeDataListener registers a listener for node data changes, it must be called before the store is used
+	AddNodeDataListener(listener NodeDataListener)
+
+	StoreNodeData(data *NodeData) error
+	UpdateNodeGeoData(operatorID string, geoData GeoData) error
+	ListNodeData(onlyWithNotNilOperatorId bool) ([]NodeData, error)
+	// ForEachNodeData calls fn for every node in operator id order without loading them all,
+	// the nodes are read in one transaction and fn must neither use the store nor block, see ListNodeDataPage
+	ForEachNodeData(onlyWithNotNilOperatorId bool, fn func(data *NodeData) error) error
+	// ListNodeDataPage returns up to limit nodes with an operator id after the given one, in operator id order,
+	// so that long reads don't hold a transaction between pages
+	ListNodeDataPage(onlyWithNotNilOperatorId bool, after string, limit int) ([]NodeData, error)
+	GetNodeData(operatorID string) (*NodeData, error)
+	GetNodeByOperatorContractId(operatorContractId string) (*NodeData, error)
+	// ListNodesByIP returns the nodes announcing the ip, more than one means colocated operators
+	ListNodesByIP(ip net.IP) ([]NodeData, error)
+	// ListNodesInCIDR returns the nodes with an ip in the network, ordered by ip
+	ListNodesInCIDR(network *net.IPNet) ([]NodeData, error)
+	// ListNodesInBox returns the located nodes inside the box
+	ListNodesInBox(box GeoBox) ([]NodeData, error)
+
+	SaveOperatorAndUpdateNodeData(operator *Operator) error
+	GetOperatorByOperatorId(operatorId string) (*Operator, error)
+	GetOperatorByOperatorIdContract(operatorIdContract uint64) (*Operator, error)
+	ListOperators() ([]Operator, error)
+
+	SaveLocationClaim(operatorID string, claim *LocationClaim) error
+	GetLocationClaim(operatorID string) (*LocationClaim, error)
+
+	SaveVersionHistogram(histogram *VersionHistogram) error
+	ListVersionHistograms() ([]VersionHistogram, error)
+
+	// Revision returns the current data revision, a new revision is visible with the data of its write
+	Revision() (Revision, error)
+
+	GetLastBlock() (*big.Int, error)
+	SaveLastBlock(blockNumber *big.Int) error
+	SchemaVersion() (uint64, error)
+
+	// Export writes a consistent json lines dump of all data
+	Export(w io.Writer) error
+	// Import upserts the data of an export
+	Import(r io.Reader) (ImportStats, error)
+}
+
+var (
+	_ Store = (*BoltDB)(nil)
+	_ Store = (*SQLDB)(nil)
+)
+
+// Open opens the store of the given driver, path is used by bolt and dsn by the sql drivers
+func Open(driver, path, dsn string) (Store, error) {
+	switch driver {
+	case "", DriverBolt:
+		return NewBoltDB(path)
+	case DriverSQLite, DriverPostgres:
+		return NewSQLDB(driver, dsn)
+	default:
+		return nil, fmt.Errorf("unknown database driver: %s", driver)
+	}
+}
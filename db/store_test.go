@@ -0,0 +1,507 @@
+package db
+
+import (
+	"bytes"
+	"math/big"
+	"net"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// storeFactories open an empty store of every backend, the contract tests run against each of them
+var storeFactories = []struct {
+	name string
+	open func(t *testing.T) Store
+}{
+	{"bolt", func(t *testing.T) Store {
+		store, err := NewBoltDB(filepath.Join(t.TempDir(), "test.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return store
+	}},
+	{"sqlite", func(t *testing.T) Store {
+		store, err := NewSQLDB(DriverSQLite, ":memory:")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return store
+	}},
+}
+
+func forEachStore(t *testing.T, test func(t *testing.T, store Store)) {
+	for _, factory := range storeFactories {
+		factory := factory
+		t.Run(factory.name, func(t *testing.T) {
+			store := factory.open(t)
+			t.Cleanup(func() { _ = store.Close() })
+			test(t, store)
+		})
+	}
+}
+
+func testNode(operatorID, ip, countryCode string, latitude, longitude float64) *NodeData {
+	return &NodeData{
+		IPAddress:     ip,
+		AddressFamily: AddressFamilyIPv4,
+		NodeVersion:   "v0.4.1",
+		OperatorID:    operatorID,
+		GeoData: GeoData{
+			CountryCode: countryCode,
+			City:        "City " + operatorID,
+			Latitude:    latitude,
+			Longitude:   longitude,
+		},
+	}
+}
+
+func mustStoreNodes(t *testing.T, store Store, nodes ...*NodeData) {
+	t.Helper()
+	for _, node := range nodes {
+		if err := store.StoreNodeData(node); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func operatorIDs(nodes []NodeData) []string {
+	ids := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		ids = append(ids, node.OperatorID)
+	}
+	return ids
+}
+
+// sortedOperatorIDs is used for the box lookups, which have no defined order
+func sortedOperatorIDs(nodes []NodeData) []string {
+	ids := operatorIDs(nodes)
+	sort.Strings(ids)
+	return ids
+}
+
+func TestStoreSchemaVersion(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		version, err := store.SchemaVersion()
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := LatestSchemaVersion()
+		if _, ok := store.(*SQLDB); ok {
+			expected = uint64(len(sqlMigrations))
+		}
+		if version != expected {
+			t.Fatalf("schema version is %d, expected the migrations to be applied up to %d", version, expected)
+		}
+	})
+}
+
+func TestStoreNodes(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		if _, err := store.GetNodeData("missing"); err != ErrNotFound {
+			t.Fatalf("expected ErrNotFound, got %v", err)
+		}
+
+		var notified []string
+		store.AddNodeDataListener(func(previous, current *NodeData) {
+			notified = append(notified, current.OperatorID)
+		})
+		mustStoreNodes(t, store,
+			testNode("c", "10.0.0.3", "FR", 48.85, 2.35),
+			testNode("a", "10.0.0.1", "DE", 50.11, 8.68),
+			testNode("b", "10.0.0.2", "DE", 52.52, 13.40),
+		)
+		if !reflect.DeepEqual(notified, []string{"c", "a", "b"}) {
+			t.Fatalf("listeners notified for %v", notified)
+		}
+
+		node, err := store.GetNodeData("a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if node.IPAddress != "10.0.0.1" || node.GeoData.CountryCode != "DE" || node.UpdatedAt.IsZero() {
+			t.Fatalf("unexpected node %+v", node)
+		}
+
+		nodes, err := store.ListNodeData(false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ids := operatorIDs(nodes); !reflect.DeepEqual(ids, []string{"a", "b", "c"}) {
+			t.Fatalf("listed %v, expected operator id order", ids)
+		}
+
+		var visited []string
+		err = store.ForEachNodeData(false, func(data *NodeData) error {
+			visited = append(visited, data.OperatorID)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(visited, []string{"a", "b", "c"}) {
+			t.Fatalf("visited %v, expected operator id order", visited)
+		}
+
+		var paged []string
+		for after := ""; ; {
+			page, err := store.ListNodeDataPage(false, after, 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(page) == 0 {
+				break
+			}
+			paged = append(paged, operatorIDs(page)...)
+			after = page[len(page)-1].OperatorID
+		}
+		if !reflect.DeepEqual(paged, []string{"a", "b", "c"}) {
+			t.Fatalf("paged %v, expected operator id order", paged)
+		}
+
+		withOperator, err := store.ListNodeData(true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(withOperator) != 0 {
+			t.Fatalf("listed %d nodes without a contract operator id", len(withOperator))
+		}
+
+		moved := GeoData{CountryCode: "NL", City: "Amsterdam", Latitude: 52.37, Longitude: 4.90}
+		if err := store.UpdateNodeGeoData("a", moved); err != nil {
+			t.Fatal(err)
+		}
+		node, err = store.GetNodeData("a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if node.GeoData != moved || node.IPAddress != "10.0.0.1" {
+			t.Fatalf("geo data update changed %+v", node)
+		}
+	})
+}
+
+func TestStoreRevision(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		first, err := store.Revision()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		mustStoreNodes(t, store, testNode("a", "10.0.0.1", "DE", 50.11, 8.68))
+		afterNode, err := store.Revision()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if afterNode.Number <= first.Number {
+			t.Fatalf("revision %d not bumped by a node write from %d", afterNode.Number, first.Number)
+		}
+
+		if err := store.SaveOperatorAndUpdateNodeData(&Operator{OperatorID: "a", OperatorIDContract: 1}); err != nil {
+			t.Fatal(err)
+		}
+		afterOperator, err := store.Revision()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if afterOperator.Number <= afterNode.Number {
+			t.Fatalf("revision %d not bumped by an operator write from %d", afterOperator.Number, afterNode.Number)
+		}
+
+		if _, err := store.ListNodeData(false); err != nil {
+			t.Fatal(err)
+		}
+		afterRead, err := store.Revision()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if afterRead.Number != afterOperator.Number {
+			t.Fatalf("revision changed from %d to %d by a read", afterOperator.Number, afterRead.Number)
+		}
+	})
+}
+
+func TestStoreIPIndex(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		mustStoreNodes(t, store,
+			testNode("a", "10.0.0.1", "DE", 50.11, 8.68),
+			testNode("b", "10.0.0.1", "DE", 50.11, 8.68),
+			testNode("c", "10.0.1.1", "DE", 50.11, 8.68),
+			testNode("d", "2001:db8::1", "DE", 50.11, 8.68),
+		)
+
+		colocated, err := store.ListNodesByIP(net.ParseIP("10.0.0.1"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ids := operatorIDs(colocated); !reflect.DeepEqual(ids, []string{"a", "b"}) {
+			t.Fatalf("nodes by ip %v", ids)
+		}
+
+		_, network, _ := net.ParseCIDR("10.0.0.0/16")
+		inNetwork, err := store.ListNodesInCIDR(network)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ids := operatorIDs(inNetwork); !reflect.DeepEqual(ids, []string{"a", "b", "c"}) {
+			t.Fatalf("nodes in cidr %v", ids)
+		}
+
+		// a node announcing a new ip leaves the index entry of the old one
+		mustStoreNodes(t, store, testNode("b", "10.0.1.2", "DE", 50.11, 8.68))
+		colocated, err = store.ListNodesByIP(net.ParseIP("10.0.0.1"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ids := operatorIDs(colocated); !reflect.DeepEqual(ids, []string{"a"}) {
+			t.Fatalf("nodes by ip after the ip change %v", ids)
+		}
+
+		_, network, _ = net.ParseCIDR("2001:db8::/32")
+		inNetwork, err = store.ListNodesInCIDR(network)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ids := operatorIDs(inNetwork); !reflect.DeepEqual(ids, []string{"d"}) {
+			t.Fatalf("nodes in ipv6 cidr %v", ids)
+		}
+	})
+}
+
+func TestStoreGeoIndex(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		mustStoreNodes(t, store,
+			testNode("berlin", "10.0.0.1", "DE", 52.52, 13.40),
+			testNode("frankfurt", "10.0.0.2", "DE", 50.11, 8.68),
+			testNode("paris", "10.0.0.3", "FR", 48.85, 2.35),
+			testNode("unknown", "10.0.0.4", "", 0, 0),
+		)
+		germany := GeoBox{MinLatitude: 47, MinLongitude: 5.8, MaxLatitude: 55, MaxLongitude: 15}
+
+		inBox, err := store.ListNodesInBox(germany)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ids := sortedOperatorIDs(inBox); !reflect.DeepEqual(ids, []string{"berlin", "frankfurt"}) {
+			t.Fatalf("nodes in box %v", ids)
+		}
+
+		// a moved node leaves the index entry of its old location
+		if err := store.UpdateNodeGeoData("frankfurt", GeoData{CountryCode: "FR", Latitude: 45.76, Longitude: 4.83}); err != nil {
+			t.Fatal(err)
+		}
+		inBox, err = store.ListNodesInBox(germany)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ids := sortedOperatorIDs(inBox); !reflect.DeepEqual(ids, []string{"berlin"}) {
+			t.Fatalf("nodes in box after the move %v", ids)
+		}
+
+		world, err := store.ListNodesInBox(GeoBox{MinLatitude: -90, MinLongitude: -180, MaxLatitude: 90, MaxLongitude: 180})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ids := sortedOperatorIDs(world); !reflect.DeepEqual(ids, []string{"berlin", "frankfurt", "paris"}) {
+			t.Fatalf("located nodes %v", ids)
+		}
+	})
+}
+
+func TestStoreOperators(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		mustStoreNodes(t, store, testNode("a", "10.0.0.1", "DE", 50.11, 8.68))
+		operator := &Operator{OperatorID: "a", OperatorIDContract: 19, PublicKey: "key", Owner: "0x1", Fee: "100"}
+		if err := store.SaveOperatorAndUpdateNodeData(operator); err != nil {
+			t.Fatal(err)
+		}
+
+		stored, err := store.GetOperatorByOperatorIdContract(19)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(stored, operator) {
+			t.Fatalf("stored operator %+v, expected %+v", stored, operator)
+		}
+		if _, err := store.GetOperatorByOperatorId("missing"); err != ErrNotFound {
+			t.Fatalf("expected ErrNotFound, got %v", err)
+		}
+
+		node, err := store.GetNodeByOperatorContractId("19")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if node.OperatorID != "a" || node.OperatorIDContract != 19 {
+			t.Fatalf("node of the operator %+v", node)
+		}
+		withOperator, err := store.ListNodeData(true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ids := operatorIDs(withOperator); !reflect.DeepEqual(ids, []string{"a"}) {
+			t.Fatalf("nodes with an operator %v", ids)
+		}
+
+		// nodes seen after their operator get its contract id
+		mustStoreNodes(t, store, testNode("b", "10.0.0.2", "DE", 50.11, 8.68))
+		if err := store.SaveOperatorAndUpdateNodeData(&Operator{OperatorID: "c", OperatorIDContract: 20}); err != nil {
+			t.Fatal(err)
+		}
+		mustStoreNodes(t, store, testNode("c", "10.0.0.3", "DE", 50.11, 8.68))
+		node, err = store.GetNodeData("c")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if node.OperatorIDContract != 20 {
+			t.Fatalf("node stored after its operator has contract id %d", node.OperatorIDContract)
+		}
+
+		operators, err := store.ListOperators()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(operators) != 2 {
+			t.Fatalf("listed %d operators", len(operators))
+		}
+	})
+}
+
+func TestStoreState(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		mustStoreNodes(t, store, testNode("a", "10.0.0.1", "DE", 50.11, 8.68))
+		claim := &LocationClaim{OperatorIDContract: 19, CountryCode: "DE", City: "Berlin", Latitude: 52.52, Longitude: 13.40, Timestamp: 1678360116, Signature: "sig"}
+		if err := store.SaveLocationClaim("a", claim); err != nil {
+			t.Fatal(err)
+		}
+		node, err := store.GetNodeData("a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if node.ClaimedGeo == nil || *node.ClaimedGeo != *claim {
+			t.Fatalf("claim joined to the node %+v, expected %+v", node.ClaimedGeo, claim)
+		}
+
+		histograms := []VersionHistogram{
+			{Date: "2023-03-09", Counts: map[string]int{"v0.4.0": 2}},
+			{Date: "2023-03-10", Counts: map[string]int{"v0.4.0": 1, "v0.4.1": 3}},
+		}
+		for i := range histograms {
+			if err := store.SaveVersionHistogram(&histograms[i]); err != nil {
+				t.Fatal(err)
+			}
+		}
+		stored, err := store.ListVersionHistograms()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(stored, histograms) {
+			t.Fatalf("stored histograms %+v", stored)
+		}
+
+		if err := store.SaveLastBlock(big.NewInt(8661727)); err != nil {
+			t.Fatal(err)
+		}
+		lastBlock, err := store.GetLastBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if lastBlock.Uint64() != 8661727 {
+			t.Fatalf("last block %s", lastBlock)
+		}
+	})
+}
+
+// TestStoreExportImport imports the export of every backend into every backend
+func TestStoreExportImport(t *testing.T) {
+	for _, source := range storeFactories {
+		for _, destination := range storeFactories {
+			source, destination := source, destination
+			t.Run(source.name+"-to-"+destination.name, func(t *testing.T) {
+				from := source.open(t)
+				t.Cleanup(func() { _ = from.Close() })
+				mustStoreNodes(t, from,
+					testNode("a", "10.0.0.1", "DE", 50.11, 8.68),
+					testNode("b", "10.0.0.2", "FR", 48.85, 2.35),
+				)
+				if err := from.SaveOperatorAndUpdateNodeData(&Operator{OperatorID: "a", OperatorIDContract: 19, PublicKey: "key"}); err != nil {
+					t.Fatal(err)
+				}
+				if err := from.SaveLocationClaim("a", &LocationClaim{OperatorIDContract: 19, CountryCode: "DE", Timestamp: 1678360116}); err != nil {
+					t.Fatal(err)
+				}
+				if err := from.SaveVersionHistogram(&VersionHistogram{Date: "2023-03-09", Counts: map[string]int{"v0.4.1": 2}}); err != nil {
+					t.Fatal(err)
+				}
+				if err := from.SaveLastBlock(big.NewInt(8661727)); err != nil {
+					t.Fatal(err)
+				}
+
+				var export bytes.Buffer
+				if err := from.Export(&export); err != nil {
+					t.Fatal(err)
+				}
+				to := destination.open(t)
+				t.Cleanup(func() { _ = to.Close() })
+				stats, err := to.Import(&export)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if stats[RecordNode] != 2 || stats[RecordOperator] != 1 || stats[RecordLocationClaim] != 1 || stats[RecordVersionHistogram] != 1 {
+					t.Fatalf("import stats %v", stats)
+				}
+
+				fromNodes, err := from.ListNodeData(false)
+				if err != nil {
+					t.Fatal(err)
+				}
+				toNodes, err := to.ListNodeData(false)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for i := range fromNodes {
+					// timestamps are compared as instants, the backends keep different precisions and zones
+					if !fromNodes[i].UpdatedAt.Truncate(time.Microsecond).Equal(toNodes[i].UpdatedAt.Truncate(time.Microsecond)) {
+						t.Fatalf("imported update time %v, expected %v", toNodes[i].UpdatedAt, fromNodes[i].UpdatedAt)
+					}
+					fromNodes[i].UpdatedAt, toNodes[i].UpdatedAt = time.Time{}, time.Time{}
+				}
+				if !reflect.DeepEqual(toNodes, fromNodes) {
+					t.Fatalf("imported nodes %+v, expected %+v", toNodes, fromNodes)
+				}
+
+				operator, err := to.GetOperatorByOperatorIdContract(19)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if operator.OperatorID != "a" || operator.PublicKey != "key" {
+					t.Fatalf("imported operator %+v", operator)
+				}
+				lastBlock, err := to.GetLastBlock()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if lastBlock.Uint64() != 8661727 {
+					t.Fatalf("imported last block %s", lastBlock)
+				}
+
+				// the indexes are rebuilt on import
+				byIP, err := to.ListNodesByIP(net.ParseIP("10.0.0.2"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if ids := operatorIDs(byIP); !reflect.DeepEqual(ids, []string{"b"}) {
+					t.Fatalf("imported nodes by ip %v", ids)
+				}
+				inBox, err := to.ListNodesInBox(GeoBox{MinLatitude: 47, MinLongitude: 5.8, MaxLatitude: 55, MaxLongitude: 15})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if ids := sortedOperatorIDs(inBox); !reflect.DeepEqual(ids, []string{"a"}) {
+					t.Fatalf("imported nodes in box %v", ids)
+				}
+			})
+		}
+	}
+}
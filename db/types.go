@@ -1,17 +1,28 @@
 package db
 
 import (
+	"fmt"
 	"math/big"
+	"strconv"
 	"time"
 )
 
+const (
+	AddressFamilyIPv4 = "ipv4"
+	AddressFamilyIPv6 = "ipv6"
+)
+
 type NodeData struct {
-	UpdatedAt          time.Time `json:"updated_at"`
-	IPAddress          string    `json:"ip_address"`
-	GeoData            GeoData   `json:"geo_data"`
-	NodeVersion        string    `json:"node_version"`
-	OperatorID         string    `json:"-"`
-	OperatorIDContract uint64    `json:"operator_id"`
+	UpdatedAt          time.Time      `json:"updated_at"`
+	IPAddress          string         `json:"ip_address,omitempty"`
+	AddressFamily      string         `json:"address_family"`
+	GeoData            GeoData        `json:"geo_data"`
+	NodeVersion        string         `json:"node_version"`
+	OperatorID         string         `json:"-"`
+	OperatorIDContract uint64         `json:"operator_id"`
+	ClaimedGeo         *LocationClaim `json:"claimed_geo,omitempty"`
+	// Outdated is computed by the API and not stored
+	Outdated bool `json:"outdated"`
 }
 
 type GeoData struct {
@@ -21,14 +32,87 @@ type GeoData struct {
 	Latitude       float64 `json:"latitude"`
 	Longitude      float64 `json:"longitude"`
 	AccuracyRadius uint16  `json:"accuracy_radius"`
+	ASN            uint32  `json:"asn,omitempty"`
+	Organization   string  `json:"organization,omitempty"`
+	Provider       string  `json:"provider,omitempty"`
+}
+
+// LocationClaim is a location submitted by an operator, signed with its operator key
+type LocationClaim struct {
+	OperatorIDContract uint64  `json:"operator_id"`
+	CountryCode        string  `json:"country_code"`
+	City               string  `json:"city"`
+	Latitude           float64 `json:"latitude"`
+	Longitude          float64 `json:"longitude"`
+	Timestamp          int64   `json:"timestamp"`
+	Signature          string  `json:"signature"`
+	// Verified is set by the api when the claim is served, the signature is checked against the current operator key
+	Verified bool `json:"verified"`
+}
+
+// Message returns the bytes the operator signs for the claim
+func (c *LocationClaim) Message() []byte {
+	return []byte(fmt.Sprintf("startracker location claim\noperator_id: %d\ncountry_code: %s\ncity: %s\nlatitude: %s\nlongitude: %s\ntimestamp: %d",
+		c.OperatorIDContract,
+		c.CountryCode,
+		c.City,
+		strconv.FormatFloat(c.Latitude, 'f', -1, 64),
+		strconv.FormatFloat(c.Longitude, 'f', -1, 64),
+		c.Timestamp,
+	))
 }
 
 type Operator struct {
 	OperatorIDContract uint64
 	PublicKey          string
 	OperatorID         string
+	Owner              string
+	// Fee is the current fee per block in wei, DeclaredFee the pending fee change if any
+	Fee               string
+	DeclaredFee       string
+	FeeHistory        []FeeChange
+	RegistrationBlock uint64
+}
+
+// fee change types
+const (
+	FeeChangeRegistered = "registered"
+	FeeChangeDeclared   = "declared"
+	FeeChangeExecuted   = "executed"
+	FeeChangeCancelled  = "cancelled"
+)
+
+// FeeChange is an operator fee event, Fee is in wei per block
+type FeeChange struct {
+	Type  string `json:"type"`
+	Block uint64 `json:"block"`
+	Fee   string `json:"fee,omitempty"`
+}
+
+// AddFeeChange appends the change to the fee history unless it was already recorded
+func (o *Operator) AddFeeChange(change FeeChange) bool {
+	for _, recorded := range o.FeeHistory {
+		if recorded == change {
+			return false
+		}
+	}
+	o.FeeHistory = append(o.FeeHistory, change)
+	return true
+}
+
+// VersionHistogram is the number of active nodes per version on a day (YYYY-MM-DD, UTC)
+type VersionHistogram struct {
+	Date   string         `json:"date"`
+	Counts map[string]int `json:"counts"`
+}
+
+// Revision is the version of the stored data, Number is incremented by every write to the data served by the API
+type Revision struct {
+	Number    uint64
+	UpdatedAt time.Time
 }
 
 type State struct {
-	LastBlock big.Int
+	LastBlock     big.Int
+	SchemaVersion uint64
 }
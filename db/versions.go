@@ -0,0 +1,51 @@
+package db
+
+import (
+	"encoding/json"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var versionHistogramsBucketName = []byte("VersionHistograms")
+
+func setupVersionHistogramsBucket(db *bolt.DB) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(versionHistogramsBucketName)
+		return err
+	})
+}
+
+// SaveVersionHistogram stores the node count per version for a day, replacing the previous snapshot of that day
+func (db *BoltDB) SaveVersionHistogram(histogram *VersionHistogram) error {
+	value, err := json.Marshal(histogram)
+	if err != nil {
+		return err
+	}
+	return db.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(versionHistogramsBucketName)
+		if err := bucket.Put([]byte(histogram.Date), value); err != nil {
+			return err
+		}
+		return bumpRevision(tx)
+	})
+}
+
+// ListVersionHistograms returns the daily histograms ordered by date
+func (db *BoltDB) ListVersionHistograms() ([]VersionHistogram, error) {
+	var histograms []VersionHistogram
+	err := db.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(versionHistogramsBucketName)
+		return bucket.ForEach(func(k, v []byte) error {
+			var histogram VersionHistogram
+			if err := json.Unmarshal(v, &histogram); err != nil {
+				return err
+			}
+			histograms = append(histograms, histogram)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return histograms, nil
+}
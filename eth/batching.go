@@ -0,0 +1,241 @@
+package eth
+
+import (
+	"context"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultMaxBlockRange    uint64 = 10000
+	defaultFetchConcurrency        = 4
+	defaultFetchRetries            = 5
+	defaultRetryBackoff            = time.Second
+)
+
+// rangeErrorMessages are parts of the errors returned by providers when a logs request covers too many blocks or results
+var rangeErrorMessages = []string{
+	"too many results",
+	"query returned more than",
+	"block range",
+	"range is too",
+	"range too large",
+	"is limited to",
+	"response size",
+	"size exceeded",
+}
+
+// queryTimeoutMessages are parts of the errors returned by providers when a logs request took too long to answer,
+// a smaller range helps but the rejected size may be accepted again once the provider is less loaded
+var queryTimeoutMessages = []string{
+	"query timeout exceeded",
+}
+
+// rateLimitMessages are parts of the errors returned by rate limited providers, smaller ranges would not help
+var rateLimitMessages = []string{
+	"rate limit",
+	"too many requests",
+	"request count",
+}
+
+func isRangeError(err error) bool {
+	return matchesAny(err, rangeErrorMessages) && !matchesAny(err, rateLimitMessages)
+}
+
+func isQueryTimeout(err error) bool {
+	return matchesAny(err, queryTimeoutMessages)
+}
+
+func matchesAny(err error, parts []string) bool {
+	message := strings.ToLower(err.Error())
+	for _, part := range parts {
+		if strings.Contains(message, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// blockRange is the number of blocks fetched in one logs request,
+// it is halved when a provider rejects a range and grows back on success, staying below the rejected sizes
+type blockRange struct {
+	mu   sync.Mutex
+	size uint64
+	max  uint64
+}
+
+func newBlockRange(max uint64) *blockRange {
+	return &blockRange{size: max, max: max}
+}
+
+func (r *blockRange) get() uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.size
+}
+
+// shrink lowers the size below the rejected size, limit also keeps it below the rejected size when growing back
+func (r *blockRange) shrink(rejected uint64, limit bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if limit && rejected <= r.max {
+		r.max = rejected - 1
+	}
+	if size := rejected / 2; size < r.size {
+		if size == 0 {
+			size = 1
+		}
+		r.size = size
+	}
+}
+
+func (r *blockRange) grow() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.size += r.size/4 + 1
+	if r.size > r.max {
+		r.size = r.max
+	}
+}
+
+type window struct {
+	index    int
+	from, to uint64
+}
+
+type windowResult struct {
+	window
+	logs []types.Log
+	err  error
+}
+
+// syncRange fetches the logs of the inclusive block range in windows fetched in parallel,
+// handle is called for every log and done after every window with its last block, both in block order
+func (e *EthEvents) syncRange(fromBlock, toBlock uint64, handle func(vLog types.Log) error, done func(toBlock uint64)) error {
+	if fromBlock > toBlock {
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(e.ctx)
+	defer cancel()
+
+	// tokens bound the windows fetched but not handled yet, a slow window can't make the others pile up
+	tokens := make(chan struct{}, 2*e.config.FetchConcurrency)
+	windows := make(chan window)
+	results := make(chan windowResult)
+
+	go func() {
+		defer close(windows)
+		for from, index := fromBlock, 0; from <= toBlock; index++ {
+			to := toBlock
+			if size := e.blockRange.get(); toBlock-from >= size {
+				to = from + size - 1
+			}
+			select {
+			case tokens <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case windows <- window{index: index, from: from, to: to}:
+			case <-ctx.Done():
+				return
+			}
+			from = to + 1
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < e.config.FetchConcurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for w := range windows {
+				logs, err := e.fetchWindow(ctx, w.from, w.to)
+				select {
+				case results <- windowResult{window: w, logs: logs, err: err}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	pending := make(map[int]windowResult)
+	next := 0
+	for result := range results {
+		if result.err != nil {
+			return errors.Wrapf(result.err, "failed to fetch logs of blocks %d-%d", result.from, result.to)
+		}
+		pending[result.index] = result
+		for {
+			r, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			for _, vLog := range r.logs {
+				if err := handle(vLog); err != nil {
+					e.logger.Error("failed to handle new event", zap.Error(err))
+				}
+			}
+			done(r.to)
+			<-tokens
+			next++
+		}
+	}
+	return e.ctx.Err()
+}
+
+// fetchWindow fetches the logs of the inclusive block range, splitting it while the provider rejects its size
+// and retrying other errors with backoff
+func (e *EthEvents) fetchWindow(ctx context.Context, from, to uint64) ([]types.Log, error) {
+	backoff := e.config.RetryBackoff
+	for attempt := 1; ; attempt++ {
+		logs, err := e.fetchLogs(ctx, new(big.Int).SetUint64(from), new(big.Int).SetUint64(to))
+		if err == nil {
+			e.blockRange.grow()
+			return logs, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		rangeError := isRangeError(err)
+		if (rangeError || isQueryTimeout(err)) && from < to {
+			e.blockRange.shrink(to-from+1, rangeError)
+			middle := from + (to-from)/2
+			e.logger.Debug("splitting rejected block range", zap.Uint64("from", from), zap.Uint64("to", to), zap.Error(err))
+			first, err := e.fetchWindow(ctx, from, middle)
+			if err != nil {
+				return nil, err
+			}
+			second, err := e.fetchWindow(ctx, middle+1, to)
+			if err != nil {
+				return nil, err
+			}
+			return append(first, second...), nil
+		}
+
+		if attempt >= e.config.FetchRetries {
+			return nil, err
+		}
+		e.logger.Warn("failed to fetch logs, retrying", zap.Uint64("from", from), zap.Uint64("to", to), zap.Int("attempt", attempt), zap.Error(err))
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
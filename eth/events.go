@@ -4,30 +4,30 @@ import (
 	"context"
 	"math/big"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/bloxapp/ssv/eth1"
-	"github.com/bloxapp/ssv/eth1/abiparser"
 	"github.com/bloxapp/ssv/logging/fields"
-	"github.com/bloxapp/ssv/utils/format"
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/pkg/errors"
 	"github.com/stakestar/startracker/db"
 	"go.uber.org/zap"
 )
 
-const blocksInBatch uint64 = 10000
-
 var startBlock = big.NewInt(8661727)
 
 type Config struct {
-	RPCUrl          string `yaml:"RPCUrl" env:"ETH_RPC_URL" env-description:"Ethereum RPC URL"`
-	ContractAddress string `yaml:"ContractAddress" env:"ETH_CONTRACT_ADDRESS" env-description:"Ethereum contract address"`
+	RPCUrl           string        `yaml:"RPCUrl" env:"ETH_RPC_URL" env-description:"Ethereum RPC URL"`
+	ContractAddress  string        `yaml:"ContractAddress" env:"ETH_CONTRACT_ADDRESS" env-description:"Ethereum contract address"`
+	MaxBlockRange    uint64        `yaml:"MaxBlockRange" env:"ETH_MAX_BLOCK_RANGE" env-default:"10000" env-description:"Maximum number of blocks fetched in one logs request"`
+	FetchConcurrency int           `yaml:"FetchConcurrency" env:"ETH_FETCH_CONCURRENCY" env-default:"4" env-description:"Number of block ranges fetched in parallel"`
+	FetchRetries     int           `yaml:"FetchRetries" env:"ETH_FETCH_RETRIES" env-default:"5" env-description:"Attempts of a logs request before the sync fails"`
+	RetryBackoff     time.Duration `yaml:"RetryBackoff" env:"ETH_RETRY_BACKOFF" env-default:"1s" env-description:"Initial delay between logs request attempts, doubled on every retry"`
 }
 
 type EthEvents struct {
@@ -37,15 +37,34 @@ type EthEvents struct {
 
 	logger *zap.Logger
 
-	db *db.BoltDB
+	db db.Store
+
+	blockRange *blockRange
+
+	mu  sync.Mutex
+	sub ethereum.Subscription
+	wg  sync.WaitGroup
 }
 
-func NewEthEvents(ctx context.Context, config *Config, db *db.BoltDB, logger *zap.Logger) (*EthEvents, error) {
+func NewEthEvents(ctx context.Context, config *Config, db db.Store, logger *zap.Logger) (*EthEvents, error) {
+	if config.MaxBlockRange == 0 {
+		config.MaxBlockRange = defaultMaxBlockRange
+	}
+	if config.FetchConcurrency <= 0 {
+		config.FetchConcurrency = defaultFetchConcurrency
+	}
+	if config.FetchRetries <= 0 {
+		config.FetchRetries = defaultFetchRetries
+	}
+	if config.RetryBackoff <= 0 {
+		config.RetryBackoff = defaultRetryBackoff
+	}
 	return &EthEvents{
-		config: config,
-		ctx:    ctx,
-		logger: logger,
-		db:     db,
+		config:     config,
+		ctx:        ctx,
+		logger:     logger,
+		db:         db,
+		blockRange: newBlockRange(config.MaxBlockRange),
 	}, nil
 }
 
@@ -75,7 +94,7 @@ func (e *EthEvents) FetchAddedOperatorEvents() error {
 
 	abiParser := eth1.NewParser(e.logger, 0)
 
-	e.logger.Info("fetching operator added events")
+	e.logger.Info("fetching operator events")
 
 	currentBlock, err := e.client.BlockNumber(e.ctx)
 	if err != nil {
@@ -93,40 +112,49 @@ func (e *EthEvents) FetchAddedOperatorEvents() error {
 
 	e.logger.Info("fetching events from block", zap.String("from", startBlock.String()))
 
-	var toBlock *big.Int
-	var stop = false
-	for {
-		if currentBlock-startBlock.Uint64() > blocksInBatch {
-			toBlock = big.NewInt(int64(startBlock.Uint64() + blocksInBatch))
-		} else {
-			toBlock = big.NewInt(int64(currentBlock))
-			stop = true
+	err = e.syncRange(startBlock.Uint64(), currentBlock, func(vLog types.Log) error {
+		return e.handeNewEvent(vLog, contractAbi, abiParser)
+	}, func(toBlock uint64) {
+		startBlock = new(big.Int).SetUint64(toBlock)
+		if err := e.db.SaveLastBlock(startBlock); err != nil {
+			e.logger.Error("failed to save last block", zap.Error(err))
 		}
+	})
+	if err != nil {
+		return err
+	}
 
-		err = e.fetchEvents(startBlock, toBlock, contractAbi, abiParser)
-		if err != nil {
-			return err
-		}
+	e.logger.Info("finished fetching events")
+	return nil
+}
 
-		startBlock = toBlock
-		err = e.db.SaveLastBlock(toBlock)
-		if err != nil {
-			e.logger.Error("failed to save last block", zap.Error(err))
-		}
-		if stop {
-			e.logger.Info("finished fetching events")
-			break
-		}
+// Close stops listening to events, waits for the event being handled and disconnects
+func (e *EthEvents) Close() error {
+	e.mu.Lock()
+	if e.sub != nil {
+		e.sub.Unsubscribe()
 	}
+	e.mu.Unlock()
 
+	e.wg.Wait()
+	if e.client != nil {
+		e.client.Close()
+	}
 	return nil
 }
 
 func (e *EthEvents) reconnect(retries int, delay time.Duration) {
 	for i := 0; i < retries; i++ {
+		if e.ctx.Err() != nil {
+			return
+		}
 		if err := e.connect(); err != nil {
 			e.logger.Warn("connection failed, retrying...", zap.Int("retry", i+1), zap.Error(err))
-			time.Sleep(delay)
+			select {
+			case <-e.ctx.Done():
+				return
+			case <-time.After(delay):
+			}
 			continue
 		}
 
@@ -150,20 +178,15 @@ func (e *EthEvents) ListenAddedOperatorEvents() error {
 
 	abiParser := eth1.NewParser(e.logger, 0)
 
-	e.logger.Info("listening to operator added events")
+	e.logger.Info("listening to operator events")
 
 	contractAddress := common.HexToAddress(e.config.ContractAddress)
 
-	eventSignature := []byte("OperatorAdded(uint64,address,bytes,uint256)")
-	hash := crypto.Keccak256Hash(eventSignature)
-
 	query := ethereum.FilterQuery{
 		Addresses: []common.Address{
 			contractAddress,
 		},
-		Topics: [][]common.Hash{{
-			hash,
-		}},
+		Topics: [][]common.Hash{operatorEventTopics},
 	}
 
 	logs := make(chan types.Log)
@@ -172,8 +195,13 @@ func (e *EthEvents) ListenAddedOperatorEvents() error {
 	if err != nil {
 		return errors.Wrap(err, "Failed to subscribe to logs")
 	}
+	e.mu.Lock()
+	e.sub = sub
+	e.mu.Unlock()
 
+	e.wg.Add(1)
 	go func() {
+		defer e.wg.Done()
 		if err := e.listenToSubscription(sub, logs, contractAbi, abiParser); err != nil {
 			e.reconnect(5, 10*time.Second)
 		}
@@ -185,7 +213,13 @@ func (e *EthEvents) ListenAddedOperatorEvents() error {
 func (e *EthEvents) listenToSubscription(sub ethereum.Subscription, logs chan types.Log, contractAbi abi.ABI, abiParser eth1.AbiParser) error {
 	for {
 		select {
-		case err := <-sub.Err():
+		case <-e.ctx.Done():
+			return nil
+		case err, ok := <-sub.Err():
+			if !ok {
+				// unsubscribed
+				return nil
+			}
 			e.logger.Warn("failed to read logs from subscription", zap.Error(err))
 			return err
 		case vLog := <-logs:
@@ -197,12 +231,9 @@ func (e *EthEvents) listenToSubscription(sub ethereum.Subscription, logs chan ty
 	}
 }
 
-func (e *EthEvents) fetchEvents(fromBlock *big.Int, toBlock *big.Int, contractAbi abi.ABI, abiParser eth1.AbiParser) error {
+func (e *EthEvents) fetchLogs(ctx context.Context, fromBlock *big.Int, toBlock *big.Int) ([]types.Log, error) {
 	contractAddress := common.HexToAddress(e.config.ContractAddress)
 
-	eventSignature := []byte("OperatorAdded(uint64,address,bytes,uint256)")
-	hash := crypto.Keccak256Hash(eventSignature)
-
 	query := ethereum.FilterQuery{
 		Addresses: []common.Address{
 			contractAddress,
@@ -210,32 +241,17 @@ func (e *EthEvents) fetchEvents(fromBlock *big.Int, toBlock *big.Int, contractAb
 		FromBlock: fromBlock,
 		ToBlock:   toBlock,
 
-		Topics: [][]common.Hash{{
-			hash,
-		}},
-	}
-
-	logs, err := e.client.FilterLogs(e.ctx, query)
-	if err != nil {
-		e.logger.Error("failed to subscribe to logs", zap.Error(err))
-		return err
-	}
-
-	for _, vLog := range logs {
-		err = e.handeNewEvent(vLog, contractAbi, abiParser)
-		if err != nil {
-			e.logger.Error("failed to handle new event", zap.Error(err))
-		}
+		Topics: [][]common.Hash{operatorEventTopics},
 	}
 
-	return nil
+	return e.client.FilterLogs(ctx, query)
 }
 
 func (e *EthEvents) handeNewEvent(log types.Log, contractAbi abi.ABI, abiParser eth1.AbiParser) error {
 	if log.Removed {
 		return nil
 	}
-	parsed, err := abiParser.ParseOperatorAddedEvent(log, contractAbi)
+	event, err := e.parseEvent(log, contractAbi, abiParser)
 	if err != nil {
 		e.logger.Warn("could not parse ongoing event, the event is malformed",
 			fields.BlockNumber(log.BlockNumber),
@@ -244,19 +260,11 @@ func (e *EthEvents) handeNewEvent(log types.Log, contractAbi abi.ABI, abiParser
 		)
 		return nil
 	}
-	e.logger.Info("received operator added event", zap.Any("event", parsed))
-	err = e.saveOperator(parsed)
+	e.logger.Info("received "+event.name()+" event", event.fields()...)
+	err = event.apply(e)
 	if err != nil {
-		e.logger.Warn("could not save operator", zap.Error(err))
+		e.logger.Warn("could not apply "+event.name()+" event", zap.Error(err))
 	}
 
 	return nil
 }
-
-func (e *EthEvents) saveOperator(event *abiparser.OperatorAddedEvent) error {
-	return e.db.SaveOperatorAndUpdateNodeData(&db.Operator{
-		OperatorID:         format.OperatorID(event.PublicKey),
-		PublicKey:          string(event.PublicKey),
-		OperatorIDContract: event.OperatorId,
-	})
-}
@@ -0,0 +1,179 @@
+package eth
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/bloxapp/ssv/eth1"
+	"github.com/bloxapp/ssv/eth1/abiparser"
+	"github.com/bloxapp/ssv/utils/format"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/pkg/errors"
+	"github.com/stakestar/startracker/db"
+	"go.uber.org/zap"
+)
+
+var (
+	operatorAddedTopic                   = crypto.Keccak256Hash([]byte("OperatorAdded(uint64,address,bytes,uint256)"))
+	operatorFeeDeclaredTopic             = crypto.Keccak256Hash([]byte("OperatorFeeDeclared(address,uint64,uint256,uint256)"))
+	operatorFeeExecutedTopic             = crypto.Keccak256Hash([]byte("OperatorFeeExecuted(address,uint64,uint256,uint256)"))
+	operatorFeeDeclarationCancelledTopic = crypto.Keccak256Hash([]byte("OperatorFeeDeclarationCancelled(address,uint64)"))
+
+	// operatorEventTopics are the events the logs are filtered by
+	operatorEventTopics = []common.Hash{
+		operatorAddedTopic,
+		operatorFeeDeclaredTopic,
+		operatorFeeExecutedTopic,
+		operatorFeeDeclarationCancelledTopic,
+	}
+)
+
+// contractEvent is a parsed contract event that can be applied to the db
+type contractEvent interface {
+	name() string
+	fields() []zap.Field
+	apply(e *EthEvents) error
+}
+
+func (e *EthEvents) parseEvent(log types.Log, contractAbi abi.ABI, abiParser eth1.AbiParser) (contractEvent, error) {
+	if len(log.Topics) == 0 {
+		return nil, errors.New("log has no topics")
+	}
+	switch log.Topics[0] {
+	case operatorAddedTopic:
+		parsed, err := abiParser.ParseOperatorAddedEvent(log, contractAbi)
+		if err != nil {
+			return nil, err
+		}
+		return &operatorAdded{event: parsed, block: log.BlockNumber}, nil
+	case operatorFeeDeclaredTopic:
+		return parseFeeEvent(log, db.FeeChangeDeclared)
+	case operatorFeeExecutedTopic:
+		return parseFeeEvent(log, db.FeeChangeExecuted)
+	case operatorFeeDeclarationCancelledTopic:
+		return parseFeeEvent(log, db.FeeChangeCancelled)
+	default:
+		return nil, fmt.Errorf("unknown event topic %s", log.Topics[0].Hex())
+	}
+}
+
+// parseFeeEvent decodes the fee events, owner and operator id are indexed,
+// the data holds the declaration block and the fee except for cancellations
+func parseFeeEvent(log types.Log, changeType string) (*operatorFeeChanged, error) {
+	if len(log.Topics) != 3 {
+		return nil, fmt.Errorf("expected 3 topics, got %d", len(log.Topics))
+	}
+	event := &operatorFeeChanged{
+		changeType: changeType,
+		owner:      common.BytesToAddress(log.Topics[1].Bytes()),
+		operatorId: new(big.Int).SetBytes(log.Topics[2].Bytes()).Uint64(),
+		block:      log.BlockNumber,
+	}
+	if changeType != db.FeeChangeCancelled {
+		if len(log.Data) != 64 {
+			return nil, fmt.Errorf("expected 64 bytes of data, got %d", len(log.Data))
+		}
+		event.fee = new(big.Int).SetBytes(log.Data[32:64])
+	}
+	return event, nil
+}
+
+type operatorAdded struct {
+	event *abiparser.OperatorAddedEvent
+	block uint64
+}
+
+func (o *operatorAdded) name() string {
+	return "operator added"
+}
+
+func (o *operatorAdded) fields() []zap.Field {
+	return []zap.Field{
+		zap.Uint64("block", o.block),
+		zap.Uint64("operatorIdContract", o.event.OperatorId),
+		zap.String("operatorId", format.OperatorID(o.event.PublicKey)),
+		zap.String("owner", o.event.Owner.Hex()),
+		zap.Stringer("fee", o.event.Fee),
+	}
+}
+
+// apply upserts the operator, fee changes already recorded are kept so that replaying the event is idempotent
+func (o *operatorAdded) apply(e *EthEvents) error {
+	operatorID := format.OperatorID(o.event.PublicKey)
+	operator, err := e.db.GetOperatorByOperatorId(operatorID)
+	if err == db.ErrNotFound {
+		operator = &db.Operator{}
+	} else if err != nil {
+		return err
+	}
+
+	operator.OperatorID = operatorID
+	operator.PublicKey = string(o.event.PublicKey)
+	operator.OperatorIDContract = o.event.OperatorId
+	operator.Owner = o.event.Owner.Hex()
+	operator.RegistrationBlock = o.block
+	if o.event.Fee != nil {
+		fee := o.event.Fee.String()
+		if operator.AddFeeChange(db.FeeChange{Type: db.FeeChangeRegistered, Block: o.block, Fee: fee}) && operator.Fee == "" {
+			operator.Fee = fee
+		}
+	}
+	return e.db.SaveOperatorAndUpdateNodeData(operator)
+}
+
+type operatorFeeChanged struct {
+	changeType string
+	owner      common.Address
+	operatorId uint64
+	fee        *big.Int
+	block      uint64
+}
+
+func (o *operatorFeeChanged) name() string {
+	return "operator fee " + o.changeType
+}
+
+func (o *operatorFeeChanged) fields() []zap.Field {
+	fields := []zap.Field{
+		zap.Uint64("block", o.block),
+		zap.Uint64("operatorIdContract", o.operatorId),
+		zap.String("owner", o.owner.Hex()),
+	}
+	if o.fee != nil {
+		fields = append(fields, zap.Stringer("fee", o.fee))
+	}
+	return fields
+}
+
+// apply records the fee change, changes are applied in block order so the latest one sets the fee
+func (o *operatorFeeChanged) apply(e *EthEvents) error {
+	operator, err := e.db.GetOperatorByOperatorIdContract(o.operatorId)
+	if err != nil {
+		return errors.Wrapf(err, "could not get operator %d", o.operatorId)
+	}
+
+	change := db.FeeChange{Type: o.changeType, Block: o.block}
+	if o.fee != nil {
+		change.Fee = o.fee.String()
+	}
+	if !operator.AddFeeChange(change) {
+		return nil
+	}
+
+	switch o.changeType {
+	case db.FeeChangeDeclared:
+		operator.DeclaredFee = change.Fee
+	case db.FeeChangeExecuted:
+		operator.Fee = change.Fee
+		operator.DeclaredFee = ""
+	case db.FeeChangeCancelled:
+		operator.DeclaredFee = ""
+	}
+	if operator.Owner == "" {
+		operator.Owner = o.owner.Hex()
+	}
+	return e.db.SaveOperatorAndUpdateNodeData(operator)
+}
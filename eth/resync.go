@@ -0,0 +1,90 @@
+package eth
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bloxapp/ssv/eth1"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// ResyncResult summarizes a resync, FirstError is the error of the first event that could not be applied
+type ResyncResult struct {
+	FromBlock  uint64
+	ToBlock    uint64
+	Events     int
+	Applied    int
+	Failed     int
+	FirstError error
+}
+
+// Resync fetches the operator events of the inclusive block range again and upserts the operators,
+// toBlock 0 means the current block, with dryRun the events are only logged, the last synced block is never changed.
+// The events that can't be applied don't stop the resync, an error is returned with the result if any failed
+func (e *EthEvents) Resync(fromBlock, toBlock uint64, dryRun bool) (*ResyncResult, error) {
+	contractAbi, err := abi.JSON(strings.NewReader(eth1.ContractABI(0)))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse contract abi")
+	}
+	abiParser := eth1.NewParser(e.logger, 0)
+
+	if toBlock == 0 {
+		toBlock, err = e.client.BlockNumber(e.ctx)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get current block")
+		}
+	}
+	if fromBlock > toBlock {
+		return nil, fmt.Errorf("from block %d is after to block %d", fromBlock, toBlock)
+	}
+
+	result := &ResyncResult{FromBlock: fromBlock, ToBlock: toBlock}
+	e.logger.Info("resyncing operator events", zap.Uint64("from", fromBlock), zap.Uint64("to", toBlock), zap.Bool("dryRun", dryRun))
+
+	err = e.syncRange(fromBlock, toBlock, func(vLog types.Log) error {
+		if vLog.Removed {
+			return nil
+		}
+		event, err := e.parseEvent(vLog, contractAbi, abiParser)
+		if err != nil {
+			e.logger.Warn("could not parse event, skipping", zap.Uint64("block", vLog.BlockNumber), zap.String("txHash", vLog.TxHash.Hex()), zap.Error(err))
+			return nil
+		}
+		result.Events++
+		fields := append(event.fields(), zap.String("event", event.name()), zap.String("txHash", vLog.TxHash.Hex()))
+		if dryRun {
+			e.logger.Info("would apply event", fields...)
+			return nil
+		}
+		if err := event.apply(e); err != nil {
+			err = errors.Wrap(err, "could not apply "+event.name()+" event")
+			e.logger.Error("failed to apply event", append(fields, zap.Error(err))...)
+			result.Failed++
+			if result.FirstError == nil {
+				result.FirstError = err
+			}
+			return nil
+		}
+		result.Applied++
+		e.logger.Debug("applied event", fields...)
+		return nil
+	}, func(batchTo uint64) {
+		done := batchTo - fromBlock + 1
+		total := toBlock - fromBlock + 1
+		e.logger.Info("resync progress",
+			zap.Uint64("block", batchTo),
+			zap.String("progress", fmt.Sprintf("%.1f%%", float64(done)*100/float64(total))),
+			zap.Int("events", result.Events),
+		)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if result.Failed > 0 {
+		return result, errors.Wrapf(result.FirstError, "%d of %d events could not be applied, first error", result.Failed, result.Events)
+	}
+	return result, nil
+}
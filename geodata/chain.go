@@ -0,0 +1,108 @@
+package geodata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ChainProvider falls back between providers in order,
+// once the country is known the providers preferred for that region are asked first
+type ChainProvider struct {
+	providers []Provider
+	byName    map[string]Provider
+	regions   map[string][]string
+}
+
+func NewChainProvider(providers []Provider, regions map[string][]string) (*ChainProvider, error) {
+	byName := make(map[string]Provider, len(providers))
+	for _, p := range providers {
+		if _, ok := byName[p.Name()]; ok {
+			return nil, fmt.Errorf("duplicate geo provider name: %s", p.Name())
+		}
+		byName[p.Name()] = p
+	}
+	normalized := make(map[string][]string, len(regions))
+	for countryCode, names := range regions {
+		for _, name := range names {
+			if _, ok := byName[name]; !ok {
+				return nil, fmt.Errorf("unknown geo provider %s in region %s", name, countryCode)
+			}
+		}
+		normalized[strings.ToUpper(countryCode)] = names
+	}
+	return &ChainProvider{
+		providers: providers,
+		byName:    byName,
+		regions:   normalized,
+	}, nil
+}
+
+func (c *ChainProvider) Name() string {
+	names := make([]string, 0, len(c.providers))
+	for _, p := range c.providers {
+		names = append(names, p.Name())
+	}
+	return strings.Join(names, ",")
+}
+
+func (c *ChainProvider) Close() error {
+	var errs []string
+	for _, p := range c.providers {
+		if err := p.Close(); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func (c *ChainProvider) Watch(ctx context.Context, interval time.Duration, logger *zap.Logger) {
+	for _, p := range c.providers {
+		if w, ok := p.(Watcher); ok {
+			go w.Watch(ctx, interval, logger)
+		}
+	}
+	<-ctx.Done()
+}
+
+func (c *ChainProvider) Lookup(ip net.IP) (*Location, error) {
+	location, err := c.lookup(c.providers, ip)
+	if err != nil {
+		return nil, err
+	}
+
+	preferred, ok := c.regions[location.CountryCode]
+	if !ok || len(preferred) == 0 || preferred[0] == location.Provider {
+		return location, nil
+	}
+	providers := make([]Provider, 0, len(preferred))
+	for _, name := range preferred {
+		providers = append(providers, c.byName[name])
+	}
+	if regional, err := c.lookup(providers, ip); err == nil {
+		return regional, nil
+	}
+	return location, nil
+}
+
+func (c *ChainProvider) lookup(providers []Provider, ip net.IP) (*Location, error) {
+	var lastErr error = ErrNoLocation
+	for _, p := range providers {
+		location, err := p.Lookup(ip)
+		if err == nil {
+			return location, nil
+		}
+		if !errors.Is(err, ErrNoLocation) {
+			lastErr = err
+		}
+	}
+	return nil, lastErr
+}
@@ -0,0 +1,233 @@
+package geodata
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// csv columns besides the location fields
+const (
+	ColumnIPFrom = "ip_from"
+	ColumnIPTo   = "ip_to"
+)
+
+// defaultCSVColumns follows the IP2Location DB5 layout:
+// ip_from, ip_to, country_code, country_name, region_name, city_name, latitude, longitude
+var defaultCSVColumns = map[string]int{
+	ColumnIPFrom:     0,
+	ColumnIPTo:       1,
+	FieldCountryCode: 2,
+	FieldCountryName: 3,
+	FieldCity:        5,
+	FieldLatitude:    6,
+	FieldLongitude:   7,
+}
+
+type ipRange struct {
+	from     net.IP
+	to       net.IP
+	location Location
+}
+
+// CSVProvider looks up locations in a csv file of ip ranges such as the DB-IP and IP2Location lite exports,
+// ip columns may hold either addresses or their decimal representation
+type CSVProvider struct {
+	name    string
+	path    string
+	columns map[string]int
+
+	mu     sync.RWMutex
+	ranges []ipRange
+}
+
+func NewCSVProvider(name, path string, columns map[string]int) (*CSVProvider, error) {
+	merged := make(map[string]int, len(defaultCSVColumns))
+	for column, idx := range defaultCSVColumns {
+		merged[column] = idx
+	}
+	for column, idx := range columns {
+		merged[column] = idx
+	}
+	p := &CSVProvider{
+		name:    name,
+		path:    path,
+		columns: merged,
+	}
+	if err := p.Reload(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *CSVProvider) Name() string {
+	return p.name
+}
+
+func (p *CSVProvider) Close() error {
+	return nil
+}
+
+func (p *CSVProvider) Watch(ctx context.Context, interval time.Duration, logger *zap.Logger) {
+	watchFile(ctx, p.path, interval, logger, p.Reload)
+}
+
+// Reload parses the csv file again and swaps the ranges
+func (p *CSVProvider) Reload() error {
+	file, err := os.Open(p.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+
+	var ranges []ipRange
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		r, err := p.parseRecord(record)
+		if err != nil {
+			// skip headers and comments
+			if line == 1 {
+				continue
+			}
+			return fmt.Errorf("%s:%d: %w", p.path, line, err)
+		}
+		ranges = append(ranges, *r)
+	}
+
+	sort.Slice(ranges, func(i, j int) bool {
+		return bytes.Compare(ranges[i].from, ranges[j].from) < 0
+	})
+
+	p.mu.Lock()
+	p.ranges = ranges
+	p.mu.Unlock()
+	return nil
+}
+
+func (p *CSVProvider) parseRecord(record []string) (*ipRange, error) {
+	column := func(name string) string {
+		idx, ok := p.columns[name]
+		if !ok || idx < 0 || idx >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[idx])
+	}
+	float := func(name string) (float64, error) {
+		value := column(name)
+		if value == "" {
+			return 0, nil
+		}
+		return strconv.ParseFloat(value, 64)
+	}
+
+	from, err := parseRangeIP(column(ColumnIPFrom))
+	if err != nil {
+		return nil, err
+	}
+	to, err := parseRangeIP(column(ColumnIPTo))
+	if err != nil {
+		return nil, err
+	}
+	latitude, err := float(FieldLatitude)
+	if err != nil {
+		return nil, err
+	}
+	longitude, err := float(FieldLongitude)
+	if err != nil {
+		return nil, err
+	}
+	accuracyRadius, err := float(FieldAccuracyRadius)
+	if err != nil {
+		return nil, err
+	}
+
+	countryCode := column(FieldCountryCode)
+	if countryCode == "-" {
+		countryCode = ""
+	}
+
+	return &ipRange{
+		from: from,
+		to:   to,
+		location: Location{
+			CountryCode:    countryCode,
+			CountryName:    column(FieldCountryName),
+			City:           column(FieldCity),
+			Latitude:       latitude,
+			Longitude:      longitude,
+			AccuracyRadius: uint16(accuracyRadius),
+			Organization:   column(FieldOrganization),
+			Provider:       p.name,
+		},
+	}, nil
+}
+
+// parseRangeIP parses an address or its decimal representation into its 16 bytes form
+func parseRangeIP(value string) (net.IP, error) {
+	if strings.ContainsAny(value, ".:") {
+		ip := net.ParseIP(value)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid ip address: %s", value)
+		}
+		return ip.To16(), nil
+	}
+
+	num, ok := new(big.Int).SetString(value, 10)
+	if !ok || num.Sign() < 0 || num.BitLen() > 128 {
+		return nil, fmt.Errorf("invalid ip number: %s", value)
+	}
+	if num.BitLen() <= 32 {
+		b := make([]byte, 4)
+		num.FillBytes(b)
+		return net.IPv4(b[0], b[1], b[2], b[3]).To16(), nil
+	}
+	b := make([]byte, net.IPv6len)
+	num.FillBytes(b)
+	return b, nil
+}
+
+func (p *CSVProvider) Lookup(ip net.IP) (*Location, error) {
+	if ip.To16() == nil {
+		return nil, fmt.Errorf("invalid IP address: %s", ip)
+	}
+	ip = ip.To16()
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	// find the last range starting at or before ip
+	i := sort.Search(len(p.ranges), func(i int) bool {
+		return bytes.Compare(p.ranges[i].from, ip) > 0
+	}) - 1
+	if i < 0 || bytes.Compare(ip, p.ranges[i].to) > 0 {
+		return nil, ErrNoLocation
+	}
+
+	location := p.ranges[i].location
+	if location.CountryCode == "" && location.Latitude == 0 && location.Longitude == 0 {
+		return nil, ErrNoLocation
+	}
+	return &location, nil
+}
@@ -1,56 +1,159 @@
 package geodata
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net"
+	"os"
+	"time"
 
-	"github.com/oschwald/maxminddb-golang"
+	"github.com/stakestar/startracker/db"
+	"go.uber.org/zap"
 )
 
-type GeoData struct {
-	Country struct {
-		IsoCode string            `maxminddb:"iso_code"`
-		Names   map[string]string `maxminddb:"names"`
-	} `maxminddb:"country"`
-	City struct {
-		Names map[string]string `maxminddb:"names"`
-	} `maxminddb:"city"`
-	Location struct {
-		AccuracyRadius uint16  `maxminddb:"accuracy_radius"`
-		Latitude       float64 `maxminddb:"latitude"`
-		Longitude      float64 `maxminddb:"longitude"`
-		MetroCode      uint    `maxminddb:"metro_code"`
-		TimeZone       string  `maxminddb:"time_zone"`
-	} `maxminddb:"location"`
-}
+// ErrNoLocation is returned by providers that have no location for an ip address
+var ErrNoLocation = errors.New("no location found")
 
-type GeoIP2DB struct {
-	db *maxminddb.Reader
+// Location is the provider agnostic result of a geo lookup
+type Location struct {
+	CountryCode    string
+	CountryName    string
+	City           string
+	Latitude       float64
+	Longitude      float64
+	AccuracyRadius uint16
+	ASN            uint32
+	Organization   string
+	Provider       string
 }
 
-func NewGeoIP2DB(databaseFilePath string) (*GeoIP2DB, error) {
-	db, err := maxminddb.Open(databaseFilePath)
-	if err != nil {
-		return nil, err
+// NodeGeoData converts the location into the geo data stored for nodes
+func (l *Location) NodeGeoData() db.GeoData {
+	return db.GeoData{
+		CountryCode:    l.CountryCode,
+		CountryName:    l.CountryName,
+		City:           l.City,
+		Latitude:       l.Latitude,
+		Longitude:      l.Longitude,
+		AccuracyRadius: l.AccuracyRadius,
+		ASN:            l.ASN,
+		Organization:   l.Organization,
+		Provider:       l.Provider,
 	}
-	return &GeoIP2DB{db}, nil
 }
 
-func (g *GeoIP2DB) Close() error {
-	return g.db.Close()
+// Provider resolves ip addresses to locations
+type Provider interface {
+	Name() string
+	Lookup(ip net.IP) (*Location, error)
+	Close() error
+}
+
+// Watcher is implemented by providers backed by files that can be reloaded while running
+type Watcher interface {
+	Watch(ctx context.Context, interval time.Duration, logger *zap.Logger)
+}
+
+// provider types
+const (
+	ProviderTypeMaxMind = "maxmind"
+	ProviderTypeMMDB    = "mmdb"
+	ProviderTypeCSV     = "csv"
+	ProviderTypeStatic  = "static"
+)
+
+type ProviderConfig struct {
+	Name    string            `yaml:"Name"`
+	Type    string            `yaml:"Type"`
+	Path    string            `yaml:"Path"`
+	ASNPath string            `yaml:"ASNPath"`
+	Fields  map[string]string `yaml:"Fields"`
+	Columns map[string]int    `yaml:"Columns"`
+}
+
+type Config struct {
+	// Providers are queried in order, the first one with a location wins
+	Providers []ProviderConfig `yaml:"Providers"`
+	// Regions maps a country code to the providers preferred for locations in that country
+	Regions map[string][]string `yaml:"Regions"`
+}
+
+// NewProvider creates the providers described by the config and chains them,
+// if no provider is configured a MaxMind City provider for defaultPath is used
+func NewProvider(config *Config, defaultPath string) (Provider, error) {
+	if len(config.Providers) == 0 {
+		return NewMaxMindProvider(ProviderTypeMaxMind, defaultPath, "")
+	}
+
+	var providers []Provider
+	closeAll := func() {
+		for _, p := range providers {
+			_ = p.Close()
+		}
+	}
+	for _, pc := range config.Providers {
+		p, err := newProvider(&pc)
+		if err != nil {
+			closeAll()
+			return nil, fmt.Errorf("could not create geo provider %s: %w", pc.Name, err)
+		}
+		providers = append(providers, p)
+	}
+
+	if len(providers) == 1 && len(config.Regions) == 0 {
+		return providers[0], nil
+	}
+	return NewChainProvider(providers, config.Regions)
 }
 
-func (g *GeoIP2DB) GetGeoDataFromIPAddress(ipAddress string) (*GeoData, error) {
-	ip := net.ParseIP(ipAddress)
-	if ip == nil {
-		return nil, fmt.Errorf("invalid IP address: %s", ipAddress)
+func newProvider(pc *ProviderConfig) (Provider, error) {
+	name := pc.Name
+	if name == "" {
+		name = pc.Type
+	}
+	switch pc.Type {
+	case ProviderTypeMaxMind:
+		return NewMaxMindProvider(name, pc.Path, pc.ASNPath)
+	case ProviderTypeMMDB:
+		return NewMMDBProvider(name, pc.Path, pc.Fields)
+	case ProviderTypeCSV:
+		return NewCSVProvider(name, pc.Path, pc.Columns)
+	case ProviderTypeStatic:
+		return NewStaticProvider(name, pc.Path)
+	default:
+		return nil, fmt.Errorf("unknown geo provider type: %s", pc.Type)
 	}
+}
 
-	var geoData GeoData
-	err := g.db.Lookup(ip, &geoData)
-	if err != nil {
-		return nil, err
+// watchFile polls path and calls reload whenever its modification time changes
+func watchFile(ctx context.Context, path string, interval time.Duration, logger *zap.Logger, reload func() error) {
+	var modTime time.Time
+	if info, err := os.Stat(path); err == nil {
+		modTime = info.ModTime()
 	}
 
-	return &geoData, nil
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			info, err := os.Stat(path)
+			if err != nil {
+				logger.Warn("could not stat geo data file", zap.String("path", path), zap.Error(err))
+				continue
+			}
+			if info.ModTime().Equal(modTime) {
+				continue
+			}
+			if err := reload(); err != nil {
+				logger.Error("could not reload geo data file", zap.String("path", path), zap.Error(err))
+				continue
+			}
+			modTime = info.ModTime()
+			logger.Info("reloaded geo data file", zap.String("path", path), zap.Time("modTime", modTime))
+		}
+	}
 }
@@ -0,0 +1,107 @@
+package geodata
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type maxMindCityRecord struct {
+	Country struct {
+		IsoCode string            `maxminddb:"iso_code"`
+		Names   map[string]string `maxminddb:"names"`
+	} `maxminddb:"country"`
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+	Location struct {
+		AccuracyRadius uint16  `maxminddb:"accuracy_radius"`
+		Latitude       float64 `maxminddb:"latitude"`
+		Longitude      float64 `maxminddb:"longitude"`
+		MetroCode      uint    `maxminddb:"metro_code"`
+		TimeZone       string  `maxminddb:"time_zone"`
+	} `maxminddb:"location"`
+}
+
+type maxMindASNRecord struct {
+	AutonomousSystemNumber       uint32 `maxminddb:"autonomous_system_number"`
+	AutonomousSystemOrganization string `maxminddb:"autonomous_system_organization"`
+}
+
+// MaxMindProvider looks up locations in a MaxMind City database,
+// and optionally autonomous systems in a MaxMind ASN database
+type MaxMindProvider struct {
+	name string
+	city *MMDB
+	asn  *MMDB
+}
+
+func NewMaxMindProvider(name, cityPath, asnPath string) (*MaxMindProvider, error) {
+	city, err := OpenMMDB(cityPath)
+	if err != nil {
+		return nil, err
+	}
+	p := &MaxMindProvider{
+		name: name,
+		city: city,
+	}
+	if asnPath != "" {
+		p.asn, err = OpenMMDB(asnPath)
+		if err != nil {
+			_ = city.Close()
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+func (p *MaxMindProvider) Name() string {
+	return p.name
+}
+
+func (p *MaxMindProvider) Close() error {
+	if p.asn != nil {
+		_ = p.asn.Close()
+	}
+	return p.city.Close()
+}
+
+func (p *MaxMindProvider) Watch(ctx context.Context, interval time.Duration, logger *zap.Logger) {
+	if p.asn != nil {
+		go p.asn.Watch(ctx, interval, logger)
+	}
+	p.city.Watch(ctx, interval, logger)
+}
+
+func (p *MaxMindProvider) Lookup(ip net.IP) (*Location, error) {
+	var record maxMindCityRecord
+	found, err := p.city.Lookup(ip, &record)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, ErrNoLocation
+	}
+
+	location := &Location{
+		CountryCode:    record.Country.IsoCode,
+		CountryName:    record.Country.Names["en"],
+		City:           record.City.Names["en"],
+		Latitude:       record.Location.Latitude,
+		Longitude:      record.Location.Longitude,
+		AccuracyRadius: record.Location.AccuracyRadius,
+		Provider:       p.name,
+	}
+
+	if p.asn != nil {
+		var asn maxMindASNRecord
+		if found, err := p.asn.Lookup(ip, &asn); err == nil && found {
+			location.ASN = asn.AutonomousSystemNumber
+			location.Organization = asn.AutonomousSystemOrganization
+		}
+	}
+
+	return location, nil
+}
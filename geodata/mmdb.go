@@ -0,0 +1,65 @@
+package geodata
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/oschwald/maxminddb-golang"
+	"go.uber.org/zap"
+)
+
+// MMDB is a maxminddb reader that can be reloaded while lookups are running
+type MMDB struct {
+	path string
+	mu   sync.RWMutex
+	db   *maxminddb.Reader
+}
+
+func OpenMMDB(databaseFilePath string) (*MMDB, error) {
+	m := &MMDB{path: databaseFilePath}
+	if err := m.Reload(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *MMDB) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.db.Close()
+}
+
+// Reload opens the database file again and atomically swaps the reader,
+// lookups that are in flight finish on the previous reader before it is closed
+func (m *MMDB) Reload() error {
+	reader, err := maxminddb.Open(m.path)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	old := m.db
+	m.db = reader
+	m.mu.Unlock()
+
+	if old != nil {
+		return old.Close()
+	}
+	return nil
+}
+
+// Watch reloads the database whenever the file changes
+func (m *MMDB) Watch(ctx context.Context, interval time.Duration, logger *zap.Logger) {
+	watchFile(ctx, m.path, interval, logger, m.Reload)
+}
+
+// Lookup decodes the record of ip into result, found is false if the database has no record for ip
+func (m *MMDB) Lookup(ip net.IP, result interface{}) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, found, err := m.db.LookupNetwork(ip, result)
+	return found, err
+}
@@ -0,0 +1,137 @@
+package geodata
+
+import (
+	"context"
+	"net"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// location fields that can be mapped to record paths
+const (
+	FieldCountryCode    = "country_code"
+	FieldCountryName    = "country_name"
+	FieldCity           = "city"
+	FieldLatitude       = "latitude"
+	FieldLongitude      = "longitude"
+	FieldAccuracyRadius = "accuracy_radius"
+	FieldASN            = "asn"
+	FieldOrganization   = "organization"
+)
+
+// defaultMMDBFields follows the DB-IP and IP2Location mmdb layouts, which mirror the GeoIP2 City one
+var defaultMMDBFields = map[string]string{
+	FieldCountryCode:    "country.iso_code",
+	FieldCountryName:    "country.names.en",
+	FieldCity:           "city.names.en",
+	FieldLatitude:       "location.latitude",
+	FieldLongitude:      "location.longitude",
+	FieldAccuracyRadius: "location.accuracy_radius",
+	FieldASN:            "autonomous_system_number",
+	FieldOrganization:   "autonomous_system_organization",
+}
+
+// MMDBProvider looks up locations in any mmdb file, the record paths of each field are configurable
+type MMDBProvider struct {
+	name   string
+	db     *MMDB
+	fields map[string]string
+}
+
+func NewMMDBProvider(name, path string, fields map[string]string) (*MMDBProvider, error) {
+	db, err := OpenMMDB(path)
+	if err != nil {
+		return nil, err
+	}
+	merged := make(map[string]string, len(defaultMMDBFields))
+	for field, recordPath := range defaultMMDBFields {
+		merged[field] = recordPath
+	}
+	for field, recordPath := range fields {
+		merged[field] = recordPath
+	}
+	return &MMDBProvider{
+		name:   name,
+		db:     db,
+		fields: merged,
+	}, nil
+}
+
+func (p *MMDBProvider) Name() string {
+	return p.name
+}
+
+func (p *MMDBProvider) Close() error {
+	return p.db.Close()
+}
+
+func (p *MMDBProvider) Watch(ctx context.Context, interval time.Duration, logger *zap.Logger) {
+	p.db.Watch(ctx, interval, logger)
+}
+
+func (p *MMDBProvider) Lookup(ip net.IP) (*Location, error) {
+	var record map[string]interface{}
+	found, err := p.db.Lookup(ip, &record)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, ErrNoLocation
+	}
+
+	return &Location{
+		CountryCode:    recordString(record, p.fields[FieldCountryCode]),
+		CountryName:    recordString(record, p.fields[FieldCountryName]),
+		City:           recordString(record, p.fields[FieldCity]),
+		Latitude:       recordFloat(record, p.fields[FieldLatitude]),
+		Longitude:      recordFloat(record, p.fields[FieldLongitude]),
+		AccuracyRadius: uint16(recordFloat(record, p.fields[FieldAccuracyRadius])),
+		ASN:            uint32(recordFloat(record, p.fields[FieldASN])),
+		Organization:   recordString(record, p.fields[FieldOrganization]),
+		Provider:       p.name,
+	}, nil
+}
+
+// recordValue walks a dot separated path through nested records
+func recordValue(record map[string]interface{}, path string) interface{} {
+	if path == "" {
+		return nil
+	}
+	var value interface{} = record
+	for _, key := range strings.Split(path, ".") {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = m[key]
+	}
+	return value
+}
+
+func recordString(record map[string]interface{}, path string) string {
+	s, _ := recordValue(record, path).(string)
+	return s
+}
+
+func recordFloat(record map[string]interface{}, path string) float64 {
+	switch v := recordValue(record, path).(type) {
+	case float64:
+		return v
+	case float32:
+		return float64(v)
+	case uint64:
+		return float64(v)
+	case uint32:
+		return float64(v)
+	case uint16:
+		return float64(v)
+	case int:
+		return float64(v)
+	case int32:
+		return float64(v)
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,137 @@
+package geodata
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+// StaticOverride pins the location of every address in a network
+type StaticOverride struct {
+	CIDR           string  `yaml:"cidr"`
+	CountryCode    string  `yaml:"country_code"`
+	CountryName    string  `yaml:"country_name"`
+	City           string  `yaml:"city"`
+	Latitude       float64 `yaml:"latitude"`
+	Longitude      float64 `yaml:"longitude"`
+	AccuracyRadius uint16  `yaml:"accuracy_radius"`
+	ASN            uint32  `yaml:"asn"`
+	Organization   string  `yaml:"organization"`
+}
+
+type staticOverrideFile struct {
+	Overrides []StaticOverride `yaml:"overrides"`
+}
+
+type staticNetwork struct {
+	network  *net.IPNet
+	location Location
+}
+
+// StaticProvider looks up locations in a yaml file of manual overrides,
+// the most specific network containing the address wins
+type StaticProvider struct {
+	name string
+	path string
+
+	mu       sync.RWMutex
+	networks []staticNetwork
+}
+
+func NewStaticProvider(name, path string) (*StaticProvider, error) {
+	p := &StaticProvider{
+		name: name,
+		path: path,
+	}
+	if err := p.Reload(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *StaticProvider) Name() string {
+	return p.name
+}
+
+func (p *StaticProvider) Close() error {
+	return nil
+}
+
+func (p *StaticProvider) Watch(ctx context.Context, interval time.Duration, logger *zap.Logger) {
+	watchFile(ctx, p.path, interval, logger, p.Reload)
+}
+
+// Reload parses the override file again and swaps the networks
+func (p *StaticProvider) Reload() error {
+	content, err := os.ReadFile(p.path)
+	if err != nil {
+		return err
+	}
+	var file staticOverrideFile
+	if err := yaml.Unmarshal(content, &file); err != nil {
+		return err
+	}
+
+	networks := make([]staticNetwork, 0, len(file.Overrides))
+	for _, o := range file.Overrides {
+		_, network, err := net.ParseCIDR(o.CIDR)
+		if err != nil {
+			ip := net.ParseIP(o.CIDR)
+			if ip == nil {
+				return fmt.Errorf("invalid override network: %s", o.CIDR)
+			}
+			bits := 8 * len(ip.To16())
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			network = &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}
+		}
+		networks = append(networks, staticNetwork{
+			network: network,
+			location: Location{
+				CountryCode:    o.CountryCode,
+				CountryName:    o.CountryName,
+				City:           o.City,
+				Latitude:       o.Latitude,
+				Longitude:      o.Longitude,
+				AccuracyRadius: o.AccuracyRadius,
+				ASN:            o.ASN,
+				Organization:   o.Organization,
+				Provider:       p.name,
+			},
+		})
+	}
+
+	p.mu.Lock()
+	p.networks = networks
+	p.mu.Unlock()
+	return nil
+}
+
+func (p *StaticProvider) Lookup(ip net.IP) (*Location, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	var best *staticNetwork
+	bestSize := -1
+	for i := range p.networks {
+		n := &p.networks[i]
+		if !n.network.Contains(ip) {
+			continue
+		}
+		if size, _ := n.network.Mask.Size(); size > bestSize {
+			best, bestSize = n, size
+		}
+	}
+	if best == nil {
+		return nil, ErrNoLocation
+	}
+	location := best.location
+	return &location, nil
+}
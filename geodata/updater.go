@@ -0,0 +1,227 @@
+package geodata
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/oschwald/maxminddb-golang"
+	"go.uber.org/zap"
+)
+
+// download suffixes of the database archive and of its checksum
+const (
+	archiveSuffix  = "tar.gz"
+	checksumSuffix = "tar.gz.sha256"
+)
+
+type UpdaterConfig struct {
+	LicenseKey     string        `yaml:"LicenseKey" env:"MAXMIND_LICENSE_KEY" env-description:"MaxMind license key, geo data updates are disabled if empty"`
+	EditionID      string        `yaml:"EditionID" env:"MAXMIND_EDITION_ID" env-default:"GeoLite2-City" env-description:"MaxMind database edition to download"`
+	DownloadURL    string        `yaml:"DownloadURL" env:"MAXMIND_DOWNLOAD_URL" env-default:"https://download.maxmind.com/app/geoip_download" env-description:"MaxMind download endpoint"`
+	UpdateInterval time.Duration `yaml:"UpdateInterval" env:"GEO_DATA_UPDATE_INTERVAL" env-default:"24h" env-description:"Interval between geo data update checks"`
+	WatchInterval  time.Duration `yaml:"WatchInterval" env:"GEO_DATA_WATCH_INTERVAL" env-default:"1m" env-description:"Interval between geo data file change checks"`
+}
+
+// Updater downloads new releases of a MaxMind database and replaces the local file,
+// the running MaxMindProvider picks the new file up through Watch
+type Updater struct {
+	config *UpdaterConfig
+	path   string
+	client *http.Client
+	logger *zap.Logger
+}
+
+func NewUpdater(config *UpdaterConfig, databaseFilePath string, logger *zap.Logger) *Updater {
+	return &Updater{
+		config: config,
+		path:   databaseFilePath,
+		client: &http.Client{Timeout: 5 * time.Minute},
+		logger: logger,
+	}
+}
+
+// Enabled reports whether a license key is configured
+func (u *Updater) Enabled() bool {
+	return u.config.LicenseKey != ""
+}
+
+// Run checks for updates every UpdateInterval until the context is done
+func (u *Updater) Run(ctx context.Context) {
+	ticker := time.NewTicker(u.config.UpdateInterval)
+	defer ticker.Stop()
+	for {
+		if _, err := u.Update(ctx); err != nil {
+			u.logger.Error("could not update geo database", zap.Error(err))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Update downloads the database if the remote release is newer than the local file,
+// it returns true if the local file was replaced. The archive is checked against the published checksum
+// and the database is opened before it replaces the local file
+func (u *Updater) Update(ctx context.Context) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.downloadURL(archiveSuffix), nil)
+	if err != nil {
+		return false, u.redactURL(err)
+	}
+	if info, err := os.Stat(u.path); err == nil {
+		req.Header.Set("If-Modified-Since", info.ModTime().UTC().Format(http.TimeFormat))
+	}
+
+	res, err := u.client.Do(req)
+	if err != nil {
+		return false, u.redactURL(err)
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusNotModified:
+		u.logger.Debug("geo database is up to date")
+		return false, nil
+	case http.StatusOK:
+	default:
+		return false, fmt.Errorf("unexpected status downloading geo database: %s", res.Status)
+	}
+
+	checksum, err := u.checksum(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	tmpPath := u.path + ".download"
+	hash := sha256.New()
+	archive := io.TeeReader(res.Body, hash)
+	if err := extractDatabase(archive, tmpPath); err != nil {
+		_ = os.Remove(tmpPath)
+		return false, err
+	}
+	// the rest of the archive is part of the checksum
+	if _, err := io.Copy(io.Discard, archive); err != nil {
+		_ = os.Remove(tmpPath)
+		return false, err
+	}
+	if actual := hex.EncodeToString(hash.Sum(nil)); actual != checksum {
+		_ = os.Remove(tmpPath)
+		return false, fmt.Errorf("geo database checksum mismatch: expected %s, got %s", checksum, actual)
+	}
+	if err := verifyDatabase(tmpPath); err != nil {
+		_ = os.Remove(tmpPath)
+		return false, err
+	}
+	if err := os.Rename(tmpPath, u.path); err != nil {
+		_ = os.Remove(tmpPath)
+		return false, err
+	}
+
+	u.logger.Info("downloaded new geo database", zap.String("edition", u.config.EditionID), zap.String("path", u.path))
+	return true, nil
+}
+
+// checksum downloads the sha256 published with the archive
+func (u *Updater) checksum(ctx context.Context) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.downloadURL(checksumSuffix), nil)
+	if err != nil {
+		return "", u.redactURL(err)
+	}
+	res, err := u.client.Do(req)
+	if err != nil {
+		return "", u.redactURL(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status downloading geo database checksum: %s", res.Status)
+	}
+
+	// the file holds the hex digest followed by the archive name
+	body, err := io.ReadAll(io.LimitReader(res.Body, 1024))
+	if err != nil {
+		return "", err
+	}
+	fields := strings.Fields(string(body))
+	if len(fields) == 0 || len(fields[0]) != sha256.Size*2 {
+		return "", fmt.Errorf("invalid geo database checksum")
+	}
+	return strings.ToLower(fields[0]), nil
+}
+
+func (u *Updater) downloadURL(suffix string) string {
+	query := url.Values{}
+	query.Set("edition_id", u.config.EditionID)
+	query.Set("license_key", u.config.LicenseKey)
+	query.Set("suffix", suffix)
+	return u.config.DownloadURL + "?" + query.Encode()
+}
+
+// redactURL removes the query, which holds the license key, from the url of a request error
+func (u *Updater) redactURL(err error) error {
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) {
+		return err
+	}
+	redacted, parseErr := url.Parse(urlErr.URL)
+	if parseErr != nil {
+		urlErr.URL = u.config.DownloadURL
+		return err
+	}
+	redacted.RawQuery = ""
+	urlErr.URL = redacted.String()
+	return err
+}
+
+// extractDatabase writes the first .mmdb file found in the tar.gz stream to path
+func extractDatabase(r io.Reader, path string) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return fmt.Errorf("no .mmdb file found in archive")
+		}
+		if err != nil {
+			return err
+		}
+		if header.Typeflag != tar.TypeReg || !strings.HasSuffix(filepath.Base(header.Name), ".mmdb") {
+			continue
+		}
+
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(file, tr); err != nil {
+			file.Close()
+			return err
+		}
+		return file.Close()
+	}
+}
+
+func verifyDatabase(path string) error {
+	reader, err := maxminddb.Open(path)
+	if err != nil {
+		return fmt.Errorf("downloaded geo database is invalid: %w", err)
+	}
+	return reader.Close()
+}
@@ -0,0 +1,253 @@
+package geodata
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const testLicenseKey = "test-license-key"
+
+// testMMDB builds an empty ipv4 MaxMind database, a single search tree node without data
+func testMMDB() []byte {
+	var db bytes.Buffer
+	// the two 24 bit records of the node point to node_count, which means no data
+	db.Write([]byte{0, 0, 1, 0, 0, 1})
+	// data section separator
+	db.Write(make([]byte, 16))
+	db.WriteString("\xab\xcd\xefMaxMind.com")
+
+	str := func(s string) {
+		db.WriteByte(2<<5 | byte(len(s)))
+		db.WriteString(s)
+	}
+	uint16Field := func(key string, value uint16) {
+		str(key)
+		db.WriteByte(5<<5 | 2)
+		_ = binary.Write(&db, binary.BigEndian, value)
+	}
+	db.WriteByte(7<<5 | 9)
+	str("node_count")
+	db.WriteByte(6<<5 | 4)
+	_ = binary.Write(&db, binary.BigEndian, uint32(1))
+	uint16Field("record_size", 24)
+	uint16Field("ip_version", 4)
+	uint16Field("binary_format_major_version", 2)
+	uint16Field("binary_format_minor_version", 0)
+	str("database_type")
+	str("Test-City")
+	str("languages")
+	db.Write([]byte{1, 4})
+	str("en")
+	str("description")
+	db.WriteByte(7<<5 | 1)
+	str("en")
+	str("test database")
+	str("build_epoch")
+	db.Write([]byte{8, 2})
+	_ = binary.Write(&db, binary.BigEndian, uint64(time.Now().Unix()))
+	return db.Bytes()
+}
+
+func testArchive(t *testing.T, database []byte) []byte {
+	var archive bytes.Buffer
+	gz := gzip.NewWriter(&archive)
+	tw := tar.NewWriter(gz)
+	files := []struct {
+		name string
+		data []byte
+	}{
+		{"GeoLite2-City_20230310/COPYRIGHT.txt", []byte("copyright")},
+		{"GeoLite2-City_20230310/GeoLite2-City.mmdb", database},
+	}
+	for _, file := range files {
+		header := &tar.Header{Name: file.name, Mode: 0644, Size: int64(len(file.data)), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(file.data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return archive.Bytes()
+}
+
+// maxMindStandIn serves the archive and its checksum like the MaxMind download endpoint
+type maxMindStandIn struct {
+	t        *testing.T
+	archive  []byte
+	checksum string
+	modified time.Time
+	requests int
+}
+
+func (s *maxMindStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.requests++
+	query := r.URL.Query()
+	if query.Get("license_key") != testLicenseKey || query.Get("edition_id") != "GeoLite2-City" {
+		http.Error(w, "invalid license key", http.StatusUnauthorized)
+		return
+	}
+	switch query.Get("suffix") {
+	case archiveSuffix:
+		if since, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil && !s.modified.After(since) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Last-Modified", s.modified.UTC().Format(http.TimeFormat))
+		_, _ = w.Write(s.archive)
+	case checksumSuffix:
+		_, _ = w.Write([]byte(s.checksum + "  GeoLite2-City_20230310.tar.gz\n"))
+	default:
+		s.t.Errorf("unexpected suffix %q", query.Get("suffix"))
+		http.NotFound(w, r)
+	}
+}
+
+func newStandIn(t *testing.T, database []byte) *maxMindStandIn {
+	archive := testArchive(t, database)
+	sum := sha256.Sum256(archive)
+	return &maxMindStandIn{t: t, archive: archive, checksum: hex.EncodeToString(sum[:]), modified: time.Now().Add(-time.Hour)}
+}
+
+func newTestUpdater(serverURL, path, licenseKey string) *Updater {
+	config := &UpdaterConfig{LicenseKey: licenseKey, EditionID: "GeoLite2-City", DownloadURL: serverURL}
+	return NewUpdater(config, path, zap.NewNop())
+}
+
+func assertFile(t *testing.T, path string, expected []byte) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, expected) {
+		t.Fatalf("%s has %d bytes, expected %d", path, len(data), len(expected))
+	}
+	if _, err := os.Stat(path + ".download"); !os.IsNotExist(err) {
+		t.Fatalf("the download file was left behind: %v", err)
+	}
+}
+
+func TestUpdaterDownload(t *testing.T) {
+	database := testMMDB()
+	standIn := newStandIn(t, database)
+	server := httptest.NewServer(standIn)
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "GeoLite2-City.mmdb")
+	updater := newTestUpdater(server.URL, path, testLicenseKey)
+
+	updated, err := updater.Update(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !updated {
+		t.Fatal("the missing database was not downloaded")
+	}
+	assertFile(t, path, database)
+
+	// the local file is newer than the release
+	requests := standIn.requests
+	updated, err = updater.Update(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated || standIn.requests != requests+1 {
+		t.Fatalf("an unmodified release was downloaded again, updated %v after %d requests", updated, standIn.requests-requests)
+	}
+}
+
+func TestUpdaterKeepsDatabaseOnInvalidDownload(t *testing.T) {
+	previous := []byte("previous database")
+	cases := []struct {
+		name    string
+		standIn func(t *testing.T) *maxMindStandIn
+		error   string
+	}{
+		{"checksum mismatch", func(t *testing.T) *maxMindStandIn {
+			standIn := newStandIn(t, testMMDB())
+			standIn.checksum = strings.Repeat("0", sha256.Size*2)
+			return standIn
+		}, "checksum mismatch"},
+		{"invalid checksum", func(t *testing.T) *maxMindStandIn {
+			standIn := newStandIn(t, testMMDB())
+			standIn.checksum = "not a checksum"
+			return standIn
+		}, "invalid geo database checksum"},
+		{"invalid database", func(t *testing.T) *maxMindStandIn {
+			return newStandIn(t, []byte("not a maxmind database"))
+		}, "downloaded geo database is invalid"},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			server := httptest.NewServer(c.standIn(t))
+			defer server.Close()
+
+			path := filepath.Join(t.TempDir(), "GeoLite2-City.mmdb")
+			if err := os.WriteFile(path, previous, 0644); err != nil {
+				t.Fatal(err)
+			}
+			// the local file must look older than the release
+			old := time.Now().Add(-24 * time.Hour)
+			if err := os.Chtimes(path, old, old); err != nil {
+				t.Fatal(err)
+			}
+
+			updated, err := newTestUpdater(server.URL, path, testLicenseKey).Update(context.Background())
+			if err == nil || !strings.Contains(err.Error(), c.error) {
+				t.Fatalf("expected an error containing %q, got %v", c.error, err)
+			}
+			if updated {
+				t.Fatal("reported an update")
+			}
+			assertFile(t, path, previous)
+		})
+	}
+}
+
+func TestUpdaterErrorsHideLicenseKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "GeoLite2-City.mmdb")
+
+	server := httptest.NewServer(newStandIn(t, testMMDB()))
+	_, err := newTestUpdater(server.URL, path, "wrong-license-key").Update(context.Background())
+	if err == nil {
+		t.Fatal("expected an error with a rejected license key")
+	}
+	if strings.Contains(err.Error(), "wrong-license-key") {
+		t.Fatalf("the license key leaked into %q", err)
+	}
+
+	// the request fails once the server is gone, the error carries the url
+	server.Close()
+	_, err = newTestUpdater(server.URL, path, testLicenseKey).Update(context.Background())
+	if err == nil {
+		t.Fatal("expected an error without a server")
+	}
+	if strings.Contains(err.Error(), testLicenseKey) {
+		t.Fatalf("the license key leaked into %q", err)
+	}
+	if !strings.Contains(err.Error(), server.URL) {
+		t.Fatalf("expected the redacted url in %q", err)
+	}
+}
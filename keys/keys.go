@@ -1,7 +1,15 @@
 package keys
 
 import (
+	"crypto"
+	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"strings"
 
 	"github.com/bloxapp/ssv/utils/rsaencryption"
 )
@@ -24,3 +32,71 @@ func GenerateKeys() (*rsa.PrivateKey, string, error) {
 
 	return privateKey, operatorPubKey, nil
 }
+
+// ParsePublicKey parses an operator public key, either a PEM block or its base64 encoding as registered on chain
+func ParsePublicKey(publicKey string) (*rsa.PublicKey, error) {
+	block, err := decodePem(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	if pk, err := x509.ParsePKIXPublicKey(block.Bytes); err == nil {
+		rsaKey, ok := pk.(*rsa.PublicKey)
+		if !ok {
+			return nil, errors.New("public key is not an rsa key")
+		}
+		return rsaKey, nil
+	}
+	return x509.ParsePKCS1PublicKey(block.Bytes)
+}
+
+// ParsePrivateKey parses an operator private key, either a PEM block or its base64 encoding
+func ParsePrivateKey(privateKey string) (*rsa.PrivateKey, error) {
+	block, err := decodePem(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	if sk, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return sk, nil
+	}
+	sk, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := sk.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("private key is not an rsa key")
+	}
+	return rsaKey, nil
+}
+
+// Sign signs the sha256 digest of msg and returns the base64 encoded signature
+func Sign(sk *rsa.PrivateKey, msg []byte) (string, error) {
+	digest := sha256.Sum256(msg)
+	signature, err := rsa.SignPKCS1v15(rand.Reader, sk, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(signature), nil
+}
+
+// Verify checks a base64 encoded signature made by Sign
+func Verify(pk *rsa.PublicKey, msg []byte, signature string) error {
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return err
+	}
+	digest := sha256.Sum256(msg)
+	return rsa.VerifyPKCS1v15(pk, crypto.SHA256, digest[:], sig)
+}
+
+func decodePem(key string) (*pem.Block, error) {
+	key = strings.TrimSpace(key)
+	if decoded, err := base64.StdEncoding.DecodeString(key); err == nil {
+		key = string(decoded)
+	}
+	block, _ := pem.Decode([]byte(key))
+	if block == nil {
+		return nil, errors.New("could not decode pem key")
+	}
+	return block, nil
+}
@@ -0,0 +1,118 @@
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type component struct {
+	name string
+	stop func(ctx context.Context) error
+}
+
+// Manager owns the root context of a command, it is cancelled on SIGINT or SIGTERM,
+// components are registered in start order and stopped in reverse order
+type Manager struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	timeout time.Duration
+	logger  *zap.Logger
+
+	components []component
+	once       sync.Once
+}
+
+// New creates a manager whose context is cancelled on SIGINT, SIGTERM or when parent is done,
+// timeout bounds the stop of every single component
+func New(parent context.Context, timeout time.Duration, logger *zap.Logger) *Manager {
+	ctx, cancel := signal.NotifyContext(parent, os.Interrupt, syscall.SIGTERM)
+	return &Manager{
+		ctx:     ctx,
+		cancel:  cancel,
+		timeout: timeout,
+		logger:  logger.With(zap.String("who", "Lifecycle")),
+	}
+}
+
+// Context is the root context, components must stop their work when it is done
+func (m *Manager) Context() context.Context {
+	return m.ctx
+}
+
+// OnStop registers the stop function of a started component
+func (m *Manager) OnStop(name string, stop func(ctx context.Context) error) {
+	m.components = append(m.components, component{name: name, stop: stop})
+}
+
+// Closer registers a component stopped by Close
+func (m *Manager) Closer(name string, closer interface{ Close() error }) {
+	m.OnStop(name, func(context.Context) error {
+		return closer.Close()
+	})
+}
+
+// Go runs fn with the root context and registers a component that waits for it to return
+func (m *Manager) Go(name string, fn func(ctx context.Context)) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn(m.ctx)
+	}()
+	m.OnStop(name, func(ctx context.Context) error {
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+}
+
+// Wait blocks until the root context is done and then shuts down
+func (m *Manager) Wait() {
+	<-m.ctx.Done()
+	m.Shutdown()
+}
+
+// Shutdown cancels the root context and stops the components in reverse order,
+// a component that does not stop within the timeout is abandoned, only the first call has an effect
+func (m *Manager) Shutdown() {
+	m.once.Do(m.shutdown)
+}
+
+func (m *Manager) shutdown() {
+	m.cancel()
+	m.logger.Info("shutting down")
+	for i := len(m.components) - 1; i >= 0; i-- {
+		c := m.components[i]
+		if err := m.stop(c); err != nil {
+			m.logger.Error("could not stop component", zap.String("component", c.name), zap.Error(err))
+			continue
+		}
+		m.logger.Debug("stopped component", zap.String("component", c.name))
+	}
+	m.logger.Info("shutdown complete")
+}
+
+func (m *Manager) stop(c component) error {
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	defer cancel()
+
+	result := make(chan error, 1)
+	go func() {
+		result <- c.stop(ctx)
+	}()
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("timed out after %s", m.timeout)
+	}
+}
@@ -62,8 +62,8 @@ type Config struct {
 	ForkVersion forksprotocol.ForkVersion
 	// Logger to used by network services
 	Logger  *zap.Logger
-	DB      *db.BoltDB
-	GeoData *geodata.GeoIP2DB
+	DB      db.Store
+	GeoData geodata.Provider
 }
 
 // Libp2pOptions creates options list for the libp2p host
@@ -60,8 +60,8 @@ type p2pNetwork struct {
 	backoffConnector *libp2pdiscbackoff.BackoffConnector
 	libConnManager   connmgrcore.ConnManager
 
-	db      *db.BoltDB
-	geoData *geodata.GeoIP2DB
+	db      db.Store
+	geoData geodata.Provider
 }
 
 type P2PNetwork interface {
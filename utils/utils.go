@@ -11,6 +11,14 @@ func Uint64ToBytes(num uint64) []byte {
 	return bytes
 }
 
+// BytesToUint64 is the inverse of Uint64ToBytes, it returns 0 if b is not 8 bytes long
+func BytesToUint64(b []byte) uint64 {
+	if len(b) != 8 {
+		return 0
+	}
+	return binary.LittleEndian.Uint64(b)
+}
+
 func StringToUint64(s string) (uint64, error) {
 	num, err := strconv.ParseUint(s, 10, 64)
 	if err != nil {
@@ -0,0 +1,188 @@
+package versions
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/stakestar/startracker/db"
+	"go.uber.org/zap"
+)
+
+const dateLayout = "2006-01-02"
+
+type Config struct {
+	LatestVersion    string        `yaml:"LatestVersion" env:"LATEST_NODE_VERSION" env-description:"Latest released node version, the highest observed release is used if empty"`
+	ActiveWithin     time.Duration `yaml:"ActiveWithin" env:"VERSIONS_ACTIVE_WITHIN" env-default:"24h" env-description:"Nodes seen within this duration are counted in the daily histogram"`
+	SnapshotInterval time.Duration `yaml:"SnapshotInterval" env:"VERSIONS_SNAPSHOT_INTERVAL" env-default:"1h" env-description:"Interval between updates of the daily version histogram"`
+	// MinLatestNodes and MinLatestShare keep a few nodes announcing a bogus version from marking every node outdated
+	MinLatestNodes int     `yaml:"MinLatestNodes" env:"VERSIONS_MIN_LATEST_NODES" env-default:"3" env-description:"Minimum number of nodes running a release for it to be the observed latest version"`
+	MinLatestShare float64 `yaml:"MinLatestShare" env:"VERSIONS_MIN_LATEST_SHARE" env-default:"0.05" env-description:"Minimum share of the nodes running a release for it to be the observed latest version"`
+}
+
+// Tracker keeps a daily histogram of the versions run by active nodes
+type Tracker struct {
+	config *Config
+	db     db.Store
+	logger *zap.Logger
+	latest *Version
+}
+
+func NewTracker(config *Config, db db.Store, logger *zap.Logger) (*Tracker, error) {
+	t := &Tracker{
+		config: config,
+		db:     db,
+		logger: logger.With(zap.String("who", "VersionTracker")),
+	}
+	if config.LatestVersion != "" {
+		latest, err := Parse(config.LatestVersion)
+		if err != nil {
+			return nil, err
+		}
+		t.latest = latest
+	}
+	return t, nil
+}
+
+// Run updates the histogram of the current day every SnapshotInterval until the context is done
+func (t *Tracker) Run(ctx context.Context) {
+	ticker := time.NewTicker(t.config.SnapshotInterval)
+	defer ticker.Stop()
+	for {
+		if err := t.Snapshot(); err != nil {
+			t.logger.Error("could not save version histogram", zap.Error(err))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Snapshot counts the versions of the nodes active within ActiveWithin and stores them for the current day
+func (t *Tracker) Snapshot() error {
+	nodes, err := t.db.ListNodeData(false)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	return t.db.SaveVersionHistogram(&db.VersionHistogram{
+		Date:   now.UTC().Format(dateLayout),
+		Counts: t.Counts(nodes, now),
+	})
+}
+
+// Counts counts the versions of the nodes active within ActiveWithin at now
+func (t *Tracker) Counts(nodes []db.NodeData, now time.Time) map[string]int {
+	counts := make(map[string]int)
+	for _, node := range nodes {
+		if now.Sub(node.UpdatedAt) > t.config.ActiveWithin {
+			continue
+		}
+		counts[Canonical(node.NodeVersion)]++
+	}
+	return counts
+}
+
+// Latest returns the configured latest version, or the highest release run by enough of the given nodes
+func (t *Tracker) Latest(nodes []db.NodeData) *Version {
+	if t.latest != nil {
+		return t.latest
+	}
+	r := newReleases()
+	for i := range nodes {
+		r.add(nodes[i].NodeVersion)
+	}
+	return t.observedLatest(r)
+}
+
+// LatestStored returns the configured latest version, or the highest release run by enough of the stored nodes
+func (t *Tracker) LatestStored() (*Version, error) {
+	if t.latest != nil {
+		return t.latest, nil
+	}
+	r := newReleases()
+	err := t.db.ForEachNodeData(false, func(node *db.NodeData) error {
+		r.add(node.NodeVersion)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return t.observedLatest(r), nil
+}
+
+// releases counts the nodes running every release, out of all nodes
+type releases struct {
+	total    int
+	counts   map[string]int
+	versions map[string]*Version
+}
+
+func newReleases() *releases {
+	return &releases{counts: make(map[string]int), versions: make(map[string]*Version)}
+}
+
+func (r *releases) add(version string) {
+	r.total++
+	v, err := Parse(version)
+	if err != nil || v.Prerelease != "" {
+		return
+	}
+	key := v.String()
+	r.counts[key]++
+	r.versions[key] = v
+}
+
+// observedLatest returns the highest release run by at least MinLatestNodes nodes and MinLatestShare of the nodes
+func (t *Tracker) observedLatest(r *releases) *Version {
+	required := int(math.Ceil(t.config.MinLatestShare * float64(r.total)))
+	if required < t.config.MinLatestNodes {
+		required = t.config.MinLatestNodes
+	}
+	var latest *Version
+	for key, count := range r.counts {
+		if count < required {
+			continue
+		}
+		if v := r.versions[key]; latest == nil || latest.LessThan(v) {
+			latest = v
+		}
+	}
+	return latest
+}
+
+// IsOutdated reports whether version is lower than latest, unknown versions are not outdated
+func IsOutdated(version string, latest *Version) bool {
+	if latest == nil {
+		return false
+	}
+	v, err := Parse(version)
+	if err != nil {
+		return false
+	}
+	return v.LessThan(latest)
+}
+
+// Sorted returns the versions of a histogram from the highest to the lowest, Unknown last
+func Sorted(counts map[string]int) []string {
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, aErr := Parse(keys[i])
+		b, bErr := Parse(keys[j])
+		switch {
+		case aErr != nil:
+			return false
+		case bErr != nil:
+			return true
+		default:
+			return b.LessThan(a)
+		}
+	})
+	return keys
+}
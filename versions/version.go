@@ -0,0 +1,130 @@
+package versions
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Unknown is the histogram key of nodes whose version can't be parsed
+const Unknown = "unknown"
+
+// Version is a semantic version as reported by nodes, e.g. v0.4.1 or v0.5.0-rc.2
+type Version struct {
+	Major      uint64
+	Minor      uint64
+	Patch      uint64
+	Prerelease string
+}
+
+// Parse parses a semantic version, the v prefix, missing minor or patch numbers and build metadata are tolerated
+func Parse(version string) (*Version, error) {
+	core := strings.TrimPrefix(strings.TrimSpace(version), "v")
+	if i := strings.IndexByte(core, '+'); i >= 0 {
+		core = core[:i]
+	}
+	var v Version
+	if i := strings.IndexByte(core, '-'); i >= 0 {
+		v.Prerelease = core[i+1:]
+		core = core[:i]
+		if v.Prerelease == "" {
+			return nil, fmt.Errorf("invalid version: %s", version)
+		}
+	}
+
+	fields := strings.Split(core, ".")
+	if len(fields) > 3 {
+		return nil, fmt.Errorf("invalid version: %s", version)
+	}
+	numbers := []*uint64{&v.Major, &v.Minor, &v.Patch}
+	for i, field := range fields {
+		n, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version: %s", version)
+		}
+		*numbers[i] = n
+	}
+	return &v, nil
+}
+
+// String returns the canonical form of the version
+func (v *Version) String() string {
+	s := fmt.Sprintf("v%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.Prerelease != "" {
+		s += "-" + v.Prerelease
+	}
+	return s
+}
+
+// Compare returns -1, 0 or 1 if v is lower, equal or greater than other following semver precedence
+func (v *Version) Compare(other *Version) int {
+	if c := compareUint(v.Major, other.Major); c != 0 {
+		return c
+	}
+	if c := compareUint(v.Minor, other.Minor); c != 0 {
+		return c
+	}
+	if c := compareUint(v.Patch, other.Patch); c != 0 {
+		return c
+	}
+	return comparePrerelease(v.Prerelease, other.Prerelease)
+}
+
+// LessThan reports whether v has a lower precedence than other
+func (v *Version) LessThan(other *Version) bool {
+	return v.Compare(other) < 0
+}
+
+// Canonical returns the canonical form of a version string, or Unknown if it can't be parsed
+func Canonical(version string) string {
+	v, err := Parse(version)
+	if err != nil {
+		return Unknown
+	}
+	return v.String()
+}
+
+func compareUint(a, b uint64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// comparePrerelease compares pre-release identifiers, a release has a higher precedence than its pre-releases
+func comparePrerelease(a, b string) int {
+	switch {
+	case a == b:
+		return 0
+	case a == "":
+		return 1
+	case b == "":
+		return -1
+	}
+
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		an, aErr := strconv.ParseUint(as[i], 10, 64)
+		bn, bErr := strconv.ParseUint(bs[i], 10, 64)
+		switch {
+		case aErr == nil && bErr == nil:
+			if c := compareUint(an, bn); c != 0 {
+				return c
+			}
+		case aErr == nil:
+			// numeric identifiers have a lower precedence than alphanumeric ones
+			return -1
+		case bErr == nil:
+			return 1
+		default:
+			if c := strings.Compare(as[i], bs[i]); c != 0 {
+				return c
+			}
+		}
+	}
+	return compareUint(uint64(len(as)), uint64(len(bs)))
+}